@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// game events achievements listen for
+const (
+	eventKill    = "kill"
+	eventExplore = "explore"
+)
+
+// kills in a row without dying for Unstoppable
+const killStreakGoal = 10
+
+// how long the unlock toast stays up
+const toastTime = time.Second * 3
+
+// gameEvent is something a player did. other is the victim of a kill
+type gameEvent struct {
+	kind   string
+	userID string
+	other  string
+}
+
+// achievement is a badge unlocked the first time its check passes for an
+// event of the kind it listens for
+type achievement struct {
+	id          string
+	name        string
+	description string
+	on          string
+	check       func(wrld *world, ev gameEvent) bool
+}
+
+var achievements = []achievement{
+	{
+		id: "first-blood", name: "First Blood", description: "make your first kill",
+		on:    eventKill,
+		check: func(wrld *world, ev gameEvent) bool { return true },
+	},
+	{
+		id: "unstoppable", name: "Unstoppable", description: fmt.Sprintf("%d kills without dying", killStreakGoal),
+		on: eventKill,
+		check: func(wrld *world, ev gameEvent) bool {
+			return wrld.users[ev.userID].streak >= killStreakGoal
+		},
+	},
+	{
+		id: "last-stand", name: "Last Stand", description: "slay a monster with one life left",
+		on: eventKill,
+		check: func(wrld *world, ev gameEvent) bool {
+			return wrld.users[ev.other].isNPC && wrld.users[ev.userID].life == 1
+		},
+	},
+	// counts the cells the player has had in view, their fog of war memory,
+	// rather than the ones walked on
+	{
+		id: "cartographer", name: "Cartographer", description: "see every corner of the map",
+		on: eventExplore,
+		check: func(wrld *world, ev gameEvent) bool {
+			return wrld.locations[0].explored(wrld.users[ev.userID].seen)
+		},
+	},
+}
+
+// emit runs the achievements listening for the event, unlocking those that
+// pass for the player, and counts it toward their quests. It is called with
+// the world locked
+func (wrld *world) emit(ev gameEvent) {
+	u, ok := wrld.users[ev.userID]
+	if !ok || u.isNPC {
+		return
+	}
+	for _, a := range achievements {
+		if a.on != ev.kind || wrld.unlocked(ev.userID, a.id) || !a.check(wrld, ev) {
+			continue
+		}
+		p := wrld.profiles.get(ev.userID)
+		if p.Achievements == nil {
+			p.Achievements = make(map[string]time.Time)
+		}
+		p.Achievements[a.id] = time.Now()
+		wrld.notify(ev.userID, fmt.Sprintf("★ achievement unlocked: %s, %s", a.name, a.description))
+		wrld.toast(ev.userID, a)
+	}
+	wrld.advanceQuests(ev)
+}
+
+// toast puts up a short modal for an unlock, unless the player has another
+// modal open, and takes it down again after toastTime
+func (wrld *world) toast(userID string, a achievement) {
+	u := wrld.users[userID]
+	if u.activeModal != "" && u.activeModal != "achievement" {
+		return
+	}
+	u.modal = loadModal(textModal("★ Achievement unlocked", []string{a.name, a.description}))
+	u.activeModal = "achievement"
+	u.toastUntil = time.Now().Add(toastTime)
+	wrld.users[userID] = u
+	go func() {
+		time.Sleep(toastTime)
+		wrld.Lock()
+		defer wrld.Unlock()
+		u, ok := wrld.users[userID]
+		// a later unlock keeps its own toast up
+		if !ok || u.activeModal != "achievement" || time.Now().Before(u.toastUntil) {
+			return
+		}
+		u.modal = loadModal("")
+		u.activeModal = ""
+		wrld.users[userID] = u
+	}()
+}
+
+func (wrld *world) unlocked(userID, id string) bool {
+	p, ok := wrld.profiles.profiles[userID]
+	if !ok {
+		return false
+	}
+	_, ok = p.Achievements[id]
+	return ok
+}
+
+// walkable reports whether the cell is floor, as opposed to a wall
+func (pos *position) walkable() bool {
+	if pos.tile != nil {
+		return pos.tile.Passable || pos.tile.Toggle != ""
+	}
+	return pos.character == ' '
+}
+
+// reachableCells lists the walkable cells that can be walked to from the
+// spawn points. Some maps have walled off pockets nobody can get into
+func (loc *location) reachableCells() []string {
+	if loc.reachable != nil {
+		return loc.reachable
+	}
+	reached := make(map[string]bool)
+	queue := make([]string, 0)
+	for _, spawn := range loc.spawns {
+		reached[spawn.String()] = true
+		queue = append(queue, spawn.String())
+	}
+	loc.reachable = make([]string, 0)
+	for len(queue) > 0 {
+		cell := queue[0]
+		queue = queue[1:]
+		pos, ok := loc.positions[cell]
+		if !ok {
+			continue
+		}
+		loc.reachable = append(loc.reachable, cell)
+		for _, dir := range attackDirections {
+			next := fmt.Sprintf("%d,%d", pos.x+dir.x, pos.y+dir.y)
+			if n, ok := loc.positions[next]; ok && n.walkable() && !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return loc.reachable
+}
+
+// explored reports whether the memory holds every reachable cell
+func (loc *location) explored(seen *memory) bool {
+	if seen == nil {
+		return false
+	}
+	cells := loc.reachableCells()
+	seen.Lock()
+	defer seen.Unlock()
+	if len(seen.cells) < len(cells) {
+		return false
+	}
+	for _, cell := range cells {
+		if !seen.cells[cell] {
+			return false
+		}
+	}
+	return true
+}
+
+// achievementRows lists the user's badges for the profile modal
+func (wrld *world) achievementRows(userID string) string {
+	rows := fmt.Sprintf("│ %-27.27s │▒\n", fmt.Sprintf("Achievements: %d/%d", wrld.unlockedCount(userID), len(achievements)))
+	for _, a := range achievements {
+		if wrld.unlocked(userID, a.id) {
+			rows += fmt.Sprintf("│ %-27.27s │▒\n", "  ★ "+a.name)
+		}
+	}
+	return rows
+}
+
+func (wrld *world) unlockedCount(userID string) int {
+	n := 0
+	for _, a := range achievements {
+		if wrld.unlocked(userID, a.id) {
+			n++
+		}
+	}
+	return n
+}
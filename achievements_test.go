@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestKillAchievements(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+	w.createMonster("goblin", position{x: 2, y: 4}, w.monsterTypes["goblin"])
+	w.Lock()
+	defer w.Unlock()
+
+	tmpUser := w.users["testingUser"]
+	tmpUser.life = 1
+	w.users["testingUser"] = tmpUser
+	w.damageUser("testingUser", "goblin", 100)
+
+	for _, id := range []string{"first-blood", "last-stand"} {
+		if !w.unlocked("testingUser", id) {
+			t.Errorf("expected %s unlocked", id)
+		}
+	}
+	if w.unlocked("testingUser", "unstoppable") {
+		t.Error("expected one kill not to be a streak")
+	}
+	notices := w.users["testingUser"].notices
+	if len(notices) == 0 || !strings.Contains(notices[len(notices)-1].text, "achievement unlocked") {
+		t.Errorf("expected a toast, got %v", notices)
+	}
+	if got := w.users["testingUser"].activeModal; got != "achievement" {
+		t.Errorf("expected the unlock modal up, got %q", got)
+	}
+	if profile := w.profileModal("testingUser"); !strings.Contains(profile, "★ First Blood") {
+		t.Errorf("expected the badge in the profile:\n%s", profile)
+	}
+
+	tmpUser = w.users["testingUser"]
+	tmpUser.streak = killStreakGoal - 1
+	w.users["testingUser"] = tmpUser
+	w.damageUser("testingUser", "goblin", 100)
+	if !w.unlocked("testingUser", "unstoppable") {
+		t.Error("expected a streak to unlock unstoppable")
+	}
+}
+
+func TestCartographer(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 1)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	w.emit(gameEvent{kind: eventExplore, userID: "testingUser"})
+	if w.unlocked("testingUser", "cartographer") {
+		t.Fatal("expected the map not explored yet")
+	}
+	// walled off pockets don't count
+	all := make(map[string]bool)
+	for _, cell := range w.locations[0].reachableCells() {
+		all[cell] = true
+	}
+	w.users["testingUser"].seen.remember(all)
+	w.emit(gameEvent{kind: eventExplore, userID: "testingUser"})
+	if !w.unlocked("testingUser", "cartographer") {
+		t.Error("expected seeing everything to unlock cartographer")
+	}
+}
+
+func TestReachableCells(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_2.map", 0, 1)
+	loc := &w.locations[0]
+
+	walkable := 0
+	for _, pos := range loc.positions {
+		if pos.walkable() {
+			walkable++
+		}
+	}
+	cells := loc.reachableCells()
+	if len(cells) == 0 || len(cells) >= walkable {
+		t.Errorf("expected map_2's walled off room left out, %d of %d cells", len(cells), walkable)
+	}
+	for _, spawn := range loc.spawns {
+		cell := spawn.String()
+		found := false
+		for _, c := range cells {
+			found = found || c == cell
+		}
+		if !found {
+			t.Errorf("expected spawn %s to be reachable", cell)
+		}
+	}
+}
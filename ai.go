@@ -0,0 +1,214 @@
+package main
+
+import (
+	"container/heap"
+	"math/rand"
+)
+
+const (
+	monsterSightRadius = 8
+	// intents a monster keeps chasing a target it can no longer see
+	monsterGiveUpTicks = 5
+	// cells A* may expand before a path is considered unreachable
+	pathSearchLimit = 4000
+)
+
+var moveDirections = []string{"mw", "ma", "ms", "md"}
+
+// passable reports whether something can, and would, step onto the cell
+func (loc *location) passable(p position) bool {
+	pos, ok := loc.positions[p.String()]
+	return ok && !pos.closed && !pos.hazardous() && !loc.inSafeZone(p)
+}
+
+// findPath returns the steps from `from` to `to`, excluding `from`, moving
+// in the four directions a user can. The goal itself may be occupied, as it
+// is when chasing someone. It returns nil when there is no path
+func (loc *location) findPath(from, to position) []position {
+	start, goal := point{from.x, from.y}, point{to.x, to.y}
+
+	open := &pathQueue{}
+	heap.Push(open, pathItem{pt: start, priority: start.manhattan(goal)})
+	cameFrom := map[point]point{}
+	cost := map[point]int{start: 0}
+
+	for expanded := 0; open.Len() > 0 && expanded < pathSearchLimit; expanded++ {
+		cur := heap.Pop(open).(pathItem).pt
+		if cur == goal {
+			var path []position
+			for cur != start {
+				path = append([]position{{x: cur.x, y: cur.y}}, path...)
+				cur = cameFrom[cur]
+			}
+			return path
+		}
+		for _, dir := range moveDirections {
+			step := applyMove(position{x: cur.x, y: cur.y}, dir)
+			next := point{step.x, step.y}
+			if !loc.passable(step) && (next != goal || loc.isOpaque(next.x, next.y)) {
+				continue
+			}
+			nextCost := cost[cur] + 1
+			if c, seen := cost[next]; seen && c <= nextCost {
+				continue
+			}
+			cost[next] = nextCost
+			cameFrom[next] = cur
+			heap.Push(open, pathItem{pt: next, priority: nextCost + next.manhattan(goal)})
+		}
+	}
+	return nil
+}
+
+// point is a bare coordinate, usable as a map key
+type point struct {
+	x, y int
+}
+
+func (p position) point() point {
+	return point{p.x, p.y}
+}
+
+func (p point) manhattan(o point) int {
+	return abs(p.x-o.x) + abs(p.y-o.y)
+}
+
+type pathItem struct {
+	pt       point
+	priority int
+}
+
+type pathQueue []pathItem
+
+func (q pathQueue) Len() int            { return len(q) }
+func (q pathQueue) Less(i, j int) bool  { return q[i].priority < q[j].priority }
+func (q pathQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *pathQueue) Push(x interface{}) { *q = append(*q, x.(pathItem)) }
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+func manhattan(a, b position) int {
+	return abs(a.x-b.x) + abs(a.y-b.y)
+}
+
+// adjacent reports whether b is one of the eight cells around a, the area
+// an attack covers
+func adjacent(a, b position) bool {
+	return !(a.x == b.x && a.y == b.y) && abs(a.x-b.x) <= 1 && abs(a.y-b.y) <= 1
+}
+
+// stepToward returns the move command that takes from onto the next cell
+func stepToward(from, next position) string {
+	for _, dir := range moveDirections {
+		if p := applyMove(from, dir); p.x == next.x && p.y == next.y {
+			return dir
+		}
+	}
+	return ""
+}
+
+// monsterIntent decides what a monster does this turn and returns it as a
+// command: a move, ">attack", or "" to stay put. How it reacts to players
+// depends on its behavior. It updates the monster's chase state, so the
+// caller must hold the world lock
+func (wrld *world) monsterIntent(mID string) string {
+	m, ok := wrld.users[mID]
+	if !ok {
+		return ""
+	}
+	loc := &wrld.locations[0]
+
+	if m.behavior == behaviorPassive {
+		return moveDirections[rand.Intn(len(moveDirections))]
+	}
+
+	// closest player in sight
+	visible := loc.fieldOfView(m.position, m.sightRadius)
+	closest := ""
+	for userID, u := range wrld.users {
+		if u.isNPC || u.out || !visible[u.position.String()] || loc.inSafeZone(u.position) {
+			continue
+		}
+		if closest == "" || manhattan(m.position, u.position) < manhattan(m.position, wrld.users[closest].position) {
+			closest = userID
+		}
+	}
+
+	if closest != "" {
+		m.target = closest
+		m.lastSeen = wrld.users[closest].position
+		m.lostFor = 0
+	} else if m.target != "" {
+		m.lostFor++
+		if m.lostFor > monsterGiveUpTicks || (m.position.x == m.lastSeen.x && m.position.y == m.lastSeen.y) {
+			m.target = ""
+		}
+	}
+	wrld.users[mID] = m
+
+	if m.target == "" {
+		if wrld.bossSpec(m) != nil {
+			// bosses keep to their lairs until someone comes near
+			return ""
+		}
+		return moveDirections[rand.Intn(len(moveDirections))]
+	}
+
+	threat := m.lastSeen
+	if wrld.bossSpec(m) != nil {
+		return wrld.bossStep(m, threat)
+	}
+	fleeing := m.behavior == behaviorCoward || (m.behavior != behaviorBerserk && m.life*3 <= m.maxLife)
+	if fleeing {
+		if dir := loc.fleeFrom(m.position, threat); dir != "" {
+			return dir
+		}
+	}
+	if closest != "" && adjacent(m.position, threat) {
+		return ">attack"
+	}
+	dir := wrld.pathStep(&m, threat)
+	wrld.users[mID] = m
+	return dir
+}
+
+// pathStep returns the move along the monster's path toward goal. The path
+// is kept between turns and only searched for again when the goal has moved
+// or the monster is no longer on it, so a target standing still, or out of
+// reach, costs one search rather than one a turn
+func (wrld *world) pathStep(m *user, goal position) string {
+	loc := &wrld.locations[0]
+	for len(m.path) > 0 && m.path[0].point() == m.position.point() {
+		m.path = m.path[1:]
+	}
+	stray := len(m.path) > 0 && (manhattan(m.position, m.path[0]) != 1 ||
+		(m.path[0].point() != goal.point() && !loc.passable(m.path[0])))
+	if goal.point() != m.pathGoal.point() || stray {
+		m.path = loc.findPath(m.position, goal)
+		m.pathGoal = goal
+	}
+	if len(m.path) == 0 {
+		return ""
+	}
+	return stepToward(m.position, m.path[0])
+}
+
+// fleeFrom returns the move that takes from furthest away from threat, or
+// "" when no move gains distance
+func (loc *location) fleeFrom(from, threat position) string {
+	best, bestDist := "", manhattan(from, threat)
+	for _, dir := range moveDirections {
+		next := applyMove(from, dir)
+		if !loc.passable(next) {
+			continue
+		}
+		if d := manhattan(next, threat); d > bestDist {
+			best, bestDist = dir, d
+		}
+	}
+	return best
+}
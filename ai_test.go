@@ -0,0 +1,123 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+)
+
+func TestFindPath(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 1)
+	loc := &w.locations[0]
+
+	from, to := position{x: 2, y: 3}, position{x: 6, y: 2}
+	path := loc.findPath(from, to)
+	// down into the corridor, along it and back up around the wall at x=5
+	if len(path) != 7 {
+		t.Fatalf("unexpected path length. got %d, want %d: %v", len(path), 7, path)
+	}
+	prev := from
+	for _, step := range path {
+		if manhattan(prev, step) != 1 {
+			t.Errorf("path jumps from %s to %s", prev, step)
+		}
+		if !loc.passable(step) {
+			t.Errorf("path crosses wall at %s", step)
+		}
+		prev = step
+	}
+	if prev.String() != to.String() {
+		t.Errorf("path ends at %s, want %s", prev, to)
+	}
+
+	if path := loc.findPath(from, position{x: 5, y: 2}); path != nil {
+		t.Errorf("expected no path into a wall, got %v", path)
+	}
+}
+
+func TestMonsterIntent(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("testingUser", 80, 20, position{x: 3, y: 4}, false)
+	w.createMonster("monster", position{x: 10, y: 4}, w.monsterTypes["goblin"])
+
+	place := func(id string, x, y int) {
+		tmpUser := w.users[id]
+		tmpUser.position = position{x: x, y: y}
+		w.users[id] = tmpUser
+	}
+
+	// chase along the corridor
+	if got := w.monsterIntent("monster"); got != "ma" {
+		t.Errorf("expected monster to chase left, got %q", got)
+	}
+	if got := w.users["monster"].target; got != "testingUser" {
+		t.Errorf("unexpected target %q", got)
+	}
+
+	// attack once adjacent
+	place("testingUser", 9, 4)
+	if got := w.monsterIntent("monster"); got != ">attack" {
+		t.Errorf("expected monster to attack, got %q", got)
+	}
+
+	// flee when low on life
+	place("testingUser", 8, 4)
+	tmpUser := w.users["monster"]
+	tmpUser.life = 1
+	w.users["monster"] = tmpUser
+	got := w.monsterIntent("monster")
+	if fled := applyMove(w.users["monster"].position, got); manhattan(fled, position{x: 8, y: 4}) <= 2 {
+		t.Errorf("expected monster to flee, got %q", got)
+	}
+	tmpUser = w.users["monster"]
+	tmpUser.life = tmpUser.maxLife
+	w.users["monster"] = tmpUser
+
+	// hide behind the wall; the monster heads for where it last saw the
+	// player and eventually gives up
+	place("testingUser", 3, 6)
+	if w.locations[0].fieldOfView(w.users["monster"].position, monsterSightRadius)["3,6"] {
+		t.Fatal("test setup: 3,6 should be out of the monster's sight")
+	}
+	if got := w.monsterIntent("monster"); got != "ma" {
+		t.Errorf("expected monster to head for the last seen position, got %q", got)
+	}
+	for i := 0; i < monsterGiveUpTicks; i++ {
+		w.monsterIntent("monster")
+	}
+	if got := w.users["monster"].target; got != "" {
+		t.Errorf("expected monster to give up the chase, still targeting %q", got)
+	}
+}
+
+func TestPathReuse(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("testingUser", 80, 20, position{x: 3, y: 4}, false)
+	w.createMonster("monster", position{x: 10, y: 4}, w.monsterTypes["goblin"])
+
+	w.monsterIntent("monster")
+	path := w.users["monster"].path
+	if len(path) < 2 {
+		t.Fatalf("expected a path toward the player, got %v", path)
+	}
+
+	// one step along it, the player standing still
+	tmpUser := w.users["monster"]
+	tmpUser.position = path[0]
+	w.users["monster"] = tmpUser
+	w.monsterIntent("monster")
+	if kept := w.users["monster"].path; len(kept) != len(path)-1 || &kept[0] != &path[1] {
+		t.Errorf("expected the rest of the path followed, not searched again: %v", kept)
+	}
+
+	tmpUser = w.users["testingUser"]
+	tmpUser.position = position{x: 4, y: 4}
+	w.users["testingUser"] = tmpUser
+	w.monsterIntent("monster")
+	if m := w.users["monster"]; m.pathGoal.point() != tmpUser.position.point() || m.path[len(m.path)-1].point() != tmpUser.position.point() {
+		t.Errorf("expected a new path once the player moved, got %v to %s", m.path, m.pathGoal)
+	}
+}
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultLairRespawn = time.Minute * 5
+	// a fight is over, and the boss heals, once no player is this many
+	// cells from it
+	bossLeash = 16
+	// adds a boss summons belong to this zone, prefixed to the boss's id, so
+	// the spawner leaves them out of its own counts
+	addZonePrefix = "adds:"
+)
+
+// bossSpec sets a monster type apart as a boss: bigger than one cell, and
+// fighting in phases as its life runs down
+type bossSpec struct {
+	// cells a side of the square it covers
+	Size int `json:"size"`
+	// seconds into a fight before it enrages, doubling its damage and its
+	// speed. 0 never
+	EnrageSeconds int         `json:"enrage_seconds"`
+	Phases        []bossPhase `json:"phases"`
+}
+
+// bossPhase is what a boss does once its life has fallen to Below percent
+type bossPhase struct {
+	Below  int         `json:"below_percent"`
+	Summon *summonSpec `json:"summon"`
+	Slam   *slamSpec   `json:"slam"`
+}
+
+// summonSpec calls in monsters to fight alongside the boss
+type summonSpec struct {
+	Type string `json:"type"`
+	// adds kept alive at once
+	Count        int `json:"count"`
+	EverySeconds int `json:"every_seconds"`
+}
+
+// slamSpec is an area attack around a player, the cells it lands on shown
+// as a warning first so there is time to get out of the way
+type slamSpec struct {
+	Radius         int `json:"radius"`
+	Damage         int `json:"damage"`
+	WarningSeconds int `json:"warning_seconds"`
+	EverySeconds   int `json:"every_seconds"`
+}
+
+func (b bossSpec) validate() error {
+	if b.Size < 1 {
+		return fmt.Errorf("needs a size")
+	}
+	below := 101
+	for i, phase := range b.Phases {
+		if phase.Below <= 0 || phase.Below >= below {
+			return fmt.Errorf("phase %d needs a below_percent from 1 to 100, lower than the phase before", i+1)
+		}
+		below = phase.Below
+		if s := phase.Summon; s != nil && (s.Type == "" || s.Count <= 0 || s.EverySeconds <= 0) {
+			return fmt.Errorf("phase %d summon needs a type, count and every_seconds", i+1)
+		}
+		if s := phase.Slam; s != nil && (s.Radius <= 0 || s.Damage <= 0 || s.EverySeconds <= 0 || s.WarningSeconds < 0) {
+			return fmt.Errorf("phase %d slam needs a radius, damage and every_seconds", i+1)
+		}
+	}
+	return nil
+}
+
+// metaLair is where a boss lives, declared in a map sidecar. x and y are
+// the top left of the boss
+type metaLair struct {
+	Name           string `json:"name"`
+	Boss           string `json:"boss"`
+	X              int    `json:"x"`
+	Y              int    `json:"y"`
+	RespawnSeconds int    `json:"respawn_seconds"`
+}
+
+type lair struct {
+	name    string
+	boss    string
+	at      position
+	respawn time.Duration
+	// the living boss, "" while it is due back
+	bossID string
+	due    time.Time
+}
+
+// bossState is the fight a boss is in
+type bossState struct {
+	lair *lair
+	// phases begun, 0 before the first
+	phase int
+	// when the fight started, zero while nobody has hurt it
+	engaged    time.Time
+	enraged    bool
+	nextSummon time.Time
+	nextSlam   time.Time
+	// cells warned of, and when the slam lands on them
+	slamCells  []string
+	slamAt     time.Time
+	slamDamage int
+	// life each player has taken off it, to share the kill by
+	damageBy map[string]int
+}
+
+// newLairs checks the sidecar's lairs against the map and the bosses
+func (loc *location) newLairs(defs []metaLair, types map[string]monsterType) ([]*lair, error) {
+	lairs := make([]*lair, 0, len(defs))
+	for _, def := range defs {
+		kind, ok := types[def.Boss]
+		if !ok || kind.Boss == nil {
+			return nil, fmt.Errorf("lair %q: %q is not a boss", def.Name, def.Boss)
+		}
+		l := &lair{
+			name:    def.Name,
+			boss:    def.Boss,
+			at:      position{x: def.X, y: def.Y},
+			respawn: time.Duration(def.RespawnSeconds) * time.Second,
+		}
+		if l.respawn == 0 {
+			l.respawn = defaultLairRespawn
+		}
+		for _, cell := range square(l.at, kind.Boss.Size) {
+			if pos, ok := loc.positions[cell]; !ok || !pos.walkable() {
+				return nil, fmt.Errorf("lair %q: %s does not fit at (%d,%d)", def.Name, def.Boss, def.X, def.Y)
+			}
+		}
+		lairs = append(lairs, l)
+	}
+	return lairs, nil
+}
+
+// square lists the cells of a size by size square from its top left
+func square(anchor position, size int) []string {
+	cells := make([]string, 0, size*size)
+	for dy := 0; dy < size; dy++ {
+		for dx := 0; dx < size; dx++ {
+			cells = append(cells, position{x: anchor.x + dx, y: anchor.y + dy}.String())
+		}
+	}
+	return cells
+}
+
+// bossSpec returns the user's boss spec, nil for everyone but bosses
+func (wrld *world) bossSpec(u user) *bossSpec {
+	if !u.isNPC {
+		return nil
+	}
+	return wrld.monsterTypes[u.kind].Boss
+}
+
+// size is how many cells a side the user covers
+func (wrld *world) size(u user) int {
+	if spec := wrld.bossSpec(u); spec != nil {
+		return spec.Size
+	}
+	return 1
+}
+
+// footprint lists the cells the user stands on. Their position is the top
+// left one
+func (wrld *world) footprint(u user) []string {
+	return square(u.position, wrld.size(u))
+}
+
+// markCells puts userID on the cells, or clears them for ""
+func (loc *location) markCells(cells []string, userID string) {
+	for _, cell := range cells {
+		if pos, ok := loc.positions[cell]; ok {
+			pos.closed = userID != ""
+			pos.userID = userID
+		}
+	}
+}
+
+// fits reports whether a size by size square at anchor is free for userID:
+// open, clear of everyone else, and outside safe zones and hazards
+func (loc *location) fits(userID string, anchor position, size int) bool {
+	for _, cell := range square(anchor, size) {
+		pos, ok := loc.positions[cell]
+		if !ok || pos.userID != userID && (pos.closed || pos.userID != "") {
+			return false
+		}
+		if pos.hazardous() || loc.inSafeZone(*pos) {
+			return false
+		}
+	}
+	return true
+}
+
+// moveBoss steps a boss onto the square at to, when all of it is free
+func (wrld *world) moveBoss(bossID string, to position, now time.Time) {
+	loc := &wrld.locations[0]
+	u := wrld.users[bossID]
+	if u.deaths > 0 || u.energy < 1 || u.hasEffect(effectStun, now) {
+		return
+	}
+	if !loc.fits(bossID, to, wrld.size(u)) {
+		return
+	}
+	loc.markCells(wrld.footprint(u), "")
+	u.position = to
+	u.energy--
+	wrld.users[bossID] = u
+	loc.markCells(wrld.footprint(u), bossID)
+}
+
+// gap is how far p is from the nearest cell of a size by size square, along
+// each axis
+func gap(anchor position, size int, p position) (int, int) {
+	dx := abs(p.x - clamp(p.x, anchor.x, anchor.x+size-1))
+	dy := abs(p.y - clamp(p.y, anchor.y, anchor.y+size-1))
+	return dx, dy
+}
+
+// bossStep is a boss's intent toward the threat: attack when it is beside
+// it, otherwise the step that closes in on it. Bosses never flee
+func (wrld *world) bossStep(m user, threat position) string {
+	size := wrld.size(m)
+	dx, dy := gap(m.position, size, threat)
+	if dx <= 1 && dy <= 1 {
+		return ">attack"
+	}
+	loc := &wrld.locations[0]
+	best, bestGap := "", dx+dy
+	for _, dir := range moveDirections {
+		next := applyMove(m.position, dir)
+		if !loc.fits(m.userID, next, size) {
+			continue
+		}
+		if dx, dy := gap(next, size, threat); dx+dy < bestGap {
+			best, bestGap = dir, dx+dy
+		}
+	}
+	return best
+}
+
+// spawnBoss brings a lair's boss back once nothing stands in its way
+func (wrld *world) spawnBoss(l *lair, now time.Time) bool {
+	loc := &wrld.locations[0]
+	kind := wrld.monsterTypes[l.boss]
+	if !loc.fits("", l.at, kind.Boss.Size) {
+		return false
+	}
+	mID := strconv.Itoa(rand.Intn(2000000000))
+	if !wrld.createMonster(mID, l.at, kind) {
+		return false
+	}
+	loc.markCells(square(l.at, kind.Boss.Size), mID)
+	l.bossID = mID
+	wrld.bosses[mID] = &bossState{lair: l, damageBy: make(map[string]int)}
+	wrld.startMonster(mID)
+	log.Printf("boss %s %s spawned in %s", kind.Name, mID, l.name)
+	return true
+}
+
+// tickBosses respawns slain bosses when their lairs are due and runs the
+// fights of those alive. It is called from the game loop with the world
+// locked
+func (wrld *world) tickBosses(now time.Time) {
+	for _, l := range wrld.locations[0].lairs {
+		if l.bossID != "" {
+			if u, ok := wrld.users[l.bossID]; ok && u.deaths == 0 {
+				wrld.tickBoss(l.bossID, now)
+				continue
+			}
+			// slain; its own goroutine removes it
+			wrld.clearSlam(wrld.bosses[l.bossID])
+			delete(wrld.bosses, l.bossID)
+			l.bossID = ""
+			l.due = now.Add(l.respawn)
+		}
+		if !now.Before(l.due) {
+			wrld.spawnBoss(l, now)
+		}
+	}
+}
+
+// tickBoss moves the boss's fight along: phases as its life runs down, the
+// enrage timer, summons and slams
+func (wrld *world) tickBoss(bossID string, now time.Time) {
+	b := wrld.bosses[bossID]
+	u := wrld.users[bossID]
+	spec := wrld.bossSpec(u)
+	if b == nil || spec == nil || b.engaged.IsZero() {
+		return
+	}
+	near := wrld.nearBoss(u)
+	if len(near) == 0 {
+		wrld.resetBoss(bossID)
+		return
+	}
+
+	for b.phase < len(spec.Phases) && u.life*100 <= spec.Phases[b.phase].Below*u.maxLife {
+		b.phase++
+		b.nextSummon, b.nextSlam = now, now
+		if b.phase > 1 {
+			wrld.notifyAll(near, fmt.Sprintf("the %s enters phase %d", u.kind, b.phase))
+		}
+	}
+	if spec.EnrageSeconds > 0 && !b.enraged && now.Sub(b.engaged) >= time.Duration(spec.EnrageSeconds)*time.Second {
+		b.enraged = true
+		u.damage *= 2
+		u.speed /= 2
+		wrld.users[bossID] = u
+		wrld.notifyAll(near, fmt.Sprintf("the %s is enraged", u.kind))
+	}
+
+	if b.slamCells != nil && !now.Before(b.slamAt) {
+		wrld.landSlam(bossID, b)
+	}
+	if b.phase == 0 {
+		return
+	}
+	phase := spec.Phases[b.phase-1]
+	if phase.Summon != nil && !now.Before(b.nextSummon) {
+		wrld.summonAdds(bossID, *phase.Summon)
+		b.nextSummon = now.Add(time.Duration(phase.Summon.EverySeconds) * time.Second)
+	}
+	if phase.Slam != nil && b.slamCells == nil && !now.Before(b.nextSlam) {
+		wrld.telegraphSlam(b, *phase.Slam, near[rand.Intn(len(near))], now)
+	}
+}
+
+// nearBoss lists the players close enough to be in the boss's fight
+func (wrld *world) nearBoss(boss user) []string {
+	near := make([]string, 0)
+	for _, id := range wrld.players() {
+		u := wrld.users[id]
+		if dx, dy := gap(boss.position, wrld.size(boss), u.position); !u.out && dx <= bossLeash && dy <= bossLeash {
+			near = append(near, id)
+		}
+	}
+	return near
+}
+
+func (wrld *world) notifyAll(userIDs []string, text string) {
+	for _, id := range userIDs {
+		wrld.notify(id, text)
+	}
+}
+
+// resetBoss ends a fight everyone has walked away from. The boss heals and
+// starts over
+func (wrld *world) resetBoss(bossID string) {
+	b := wrld.bosses[bossID]
+	wrld.clearSlam(b)
+	wrld.bosses[bossID] = &bossState{lair: b.lair, damageBy: make(map[string]int)}
+	u := wrld.users[bossID]
+	u.applyMonsterType(wrld.monsterTypes[u.kind])
+	wrld.users[bossID] = u
+}
+
+// hurtBoss starts the boss's fight, if it hasn't, and notes what the
+// attacker took off it. Called by damageUser before the damage is applied
+func (wrld *world) hurtBoss(attackerID, bossID string, dealt int, now time.Time) {
+	b, ok := wrld.bosses[bossID]
+	if !ok || dealt <= 0 {
+		return
+	}
+	if life := wrld.users[bossID].life; dealt > life {
+		dealt = life
+	}
+	if b.engaged.IsZero() {
+		b.engaged = now
+	}
+	if attacker, ok := wrld.users[attackerID]; ok && !attacker.isNPC {
+		b.damageBy[attackerID] += dealt
+	}
+}
+
+// shareBossKill credits everyone who hurt a slain boss with the kill and
+// splits its xp by the damage they dealt, the killer keeping what doesn't
+// divide. It returns who shared it, nil when the victim is no boss or no
+// player hurt it
+func (wrld *world) shareBossKill(killerID string, victim user) []string {
+	b, ok := wrld.bosses[victim.userID]
+	if !ok {
+		return nil
+	}
+	total := 0
+	sharers := make([]string, 0)
+	for id, dealt := range b.damageBy {
+		if u, ok := wrld.users[id]; ok && !u.isNPC {
+			sharers = append(sharers, id)
+			total += dealt
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+	sort.Strings(sharers)
+
+	// a tile or a monster may land the killing blow
+	keeper := sharers[0]
+	if b.damageBy[killerID] > 0 {
+		keeper = killerID
+	}
+	xp := wrld.killXP(victim)
+	left := xp
+	for _, id := range sharers {
+		left -= xp * b.damageBy[id] / total
+	}
+	for _, id := range sharers {
+		u := wrld.users[id]
+		gained := xp * b.damageBy[id] / total
+		if id == keeper {
+			gained += left
+		}
+		u.kills++
+		if levels := u.gainXP(gained); levels > 0 {
+			log.Printf("user %s reached level %d", id, u.level)
+		}
+		wrld.users[id] = u
+		wrld.notify(id, fmt.Sprintf("the %s is slain, you dealt %d%% for %d xp", victim.kind, 100*b.damageBy[id]/total, gained))
+	}
+	return sharers
+}
+
+// summonAdds tops the boss's adds up to the summon's count, on the free
+// cells nearest it
+func (wrld *world) summonAdds(bossID string, s summonSpec) {
+	loc := &wrld.locations[0]
+	kind, ok := wrld.monsterTypes[s.Type]
+	if !ok {
+		return
+	}
+	zone := addZonePrefix + bossID
+	near := wrld.users[bossID].position
+	for i := wrld.zonePopulation()[zone]; i < s.Count; i++ {
+		cell := loc.freeCellNear(near)
+		pos := loc.positions[cell.String()]
+		if pos == nil || pos.closed || pos.userID != "" {
+			return
+		}
+		mID := strconv.Itoa(rand.Intn(2000000000))
+		if !wrld.createMonster(mID, cell, kind) {
+			return
+		}
+		tmpUser := wrld.users[mID]
+		tmpUser.zone = zone
+		wrld.users[mID] = tmpUser
+		loc.markCells([]string{cell.String()}, mID)
+		wrld.startMonster(mID)
+	}
+}
+
+// telegraphSlam marks the cells around the target that the slam will land
+// on once the warning runs out
+func (wrld *world) telegraphSlam(b *bossState, s slamSpec, targetID string, now time.Time) {
+	loc := &wrld.locations[0]
+	at := wrld.users[targetID].position
+	b.slamCells = make([]string, 0)
+	for y := at.y - s.Radius; y <= at.y+s.Radius; y++ {
+		for x := at.x - s.Radius; x <= at.x+s.Radius; x++ {
+			if pos, ok := loc.positions[fmt.Sprintf("%d,%d", x, y)]; ok && pos.walkable() {
+				pos.warning = true
+				b.slamCells = append(b.slamCells, pos.String())
+			}
+		}
+	}
+	b.slamAt = now.Add(time.Duration(s.WarningSeconds) * time.Second)
+	b.slamDamage = s.Damage
+	if b.enraged {
+		b.slamDamage *= 2
+	}
+	b.nextSlam = b.slamAt.Add(time.Duration(s.EverySeconds) * time.Second)
+}
+
+// landSlam hurts everyone still standing on the warned cells
+func (wrld *world) landSlam(bossID string, b *bossState) {
+	loc := &wrld.locations[0]
+	hit := make(map[string]bool)
+	for _, cell := range b.slamCells {
+		pos, ok := loc.positions[cell]
+		if !ok || pos.userID == "" || pos.userID == bossID || hit[pos.userID] {
+			continue
+		}
+		hit[pos.userID] = true
+		go areaAttack(pos)
+		wrld.damageUser(bossID, pos.userID, b.slamDamage)
+	}
+	wrld.clearSlam(b)
+}
+
+func (wrld *world) clearSlam(b *bossState) {
+	if b == nil {
+		return
+	}
+	for _, cell := range b.slamCells {
+		if pos, ok := wrld.locations[0].positions[cell]; ok {
+			pos.warning = false
+		}
+	}
+	b.slamCells = nil
+}
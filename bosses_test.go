@@ -0,0 +1,182 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+// spawnedBoss returns the id of the minotaur in map_2's lair, spawning it
+func spawnedBoss(t *testing.T, w *world, now time.Time) string {
+	w.tickBosses(now)
+	l := w.locations[0].lairs[0]
+	if l.bossID == "" {
+		t.Fatal("expected the lair to spawn its boss")
+	}
+	return l.bossID
+}
+
+func TestBossLair(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_2.map", 0, 10)
+	w.createUser("Alice", 80, 20, position{x: 115, y: 30}, false)
+	w.createUser("Bob", 80, 20, position{x: 120, y: 31}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	now := time.Now()
+	bossID := spawnedBoss(t, w, now)
+	for _, cell := range []string{"117,30", "118,30", "117,31", "118,31"} {
+		if pos := w.locations[0].positions[cell]; pos.userID != bossID || !pos.closed {
+			t.Errorf("expected the boss to cover %s", cell)
+		}
+	}
+
+	w.damageUser("Alice", bossID, 40)
+	if !w.damageUser("Bob", bossID, 100) {
+		t.Fatal("expected the boss to die")
+	}
+	alice, bob := w.users["Alice"], w.users["Bob"]
+	if alice.kills != 1 || bob.kills != 1 {
+		t.Errorf("expected both to share the kill, got %d and %d", alice.kills, bob.kills)
+	}
+	xp := w.monsterTypes["minotaur"].XP
+	if alice.xp+bob.xp != xp || alice.xp != xp*40/60 {
+		t.Errorf("expected the xp split by damage dealt, got %d and %d", alice.xp, bob.xp)
+	}
+	if w.profiles.profiles["Alice"].MonstersSlain != 1 {
+		t.Error("expected the kill in Alice's profile")
+	}
+	if pos := w.locations[0].positions["118,31"]; pos.userID != "" || pos.closed {
+		t.Error("expected the boss's cells cleared")
+	}
+
+	w.tickBosses(now)
+	l := w.locations[0].lairs[0]
+	if l.bossID != "" || w.bosses[bossID] != nil {
+		t.Fatal("expected the lair to wait before the boss returns")
+	}
+	w.tickBosses(now.Add(l.respawn))
+	if l.bossID == "" || l.bossID == bossID {
+		t.Error("expected a new boss once the lair is due")
+	}
+}
+
+func TestBossPhases(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_2.map", 0, 20)
+	w.createUser("testingUser", 80, 20, position{x: 115, y: 30}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	now := time.Now()
+	bossID := spawnedBoss(t, w, now)
+	w.teleport("testingUser", position{x: 115, y: 30})
+	tmpUser := w.users["testingUser"]
+	tmpUser.life = 20
+	tmpUser.protectedUntil = time.Time{}
+	w.users["testingUser"] = tmpUser
+
+	w.tickBosses(now)
+	if w.bosses[bossID].slamCells != nil {
+		t.Fatal("expected no slam before anyone starts the fight")
+	}
+	w.damageUser("testingUser", bossID, 1)
+	w.tickBosses(now)
+	b := w.bosses[bossID]
+	if b.phase != 1 || !w.locations[0].positions["115,30"].warning {
+		t.Fatalf("expected a slam telegraphed on the player, phase %d", b.phase)
+	}
+	w.tickBosses(now.Add(time.Second))
+	if w.users["testingUser"].life != 20 {
+		t.Fatal("expected the warning to come before the damage")
+	}
+	w.tickBosses(now.Add(time.Second * 2))
+	if w.users["testingUser"].life != 18 || w.locations[0].positions["115,30"].warning {
+		t.Errorf("expected the slam to land and clear its warning, life %d", w.users["testingUser"].life)
+	}
+
+	boss := w.users[bossID]
+	boss.life = boss.maxLife / 2
+	w.users[bossID] = boss
+	w.tickBosses(now.Add(time.Second * 3))
+	if b.phase != 2 {
+		t.Fatalf("expected the second phase at half life, got %d", b.phase)
+	}
+	if adds := w.zonePopulation()[addZonePrefix+bossID]; adds != 3 {
+		t.Errorf("expected 3 adds summoned, got %d", adds)
+	}
+	notices := w.users["testingUser"].notices
+	if len(notices) == 0 || !strings.Contains(notices[len(notices)-1].text, "phase 2") {
+		t.Errorf("expected the phase announced, got %v", notices)
+	}
+
+	damage := w.users[bossID].damage
+	w.tickBosses(b.engaged.Add(time.Second * 180))
+	if !b.enraged || w.users[bossID].damage != damage*2 {
+		t.Error("expected the boss to enrage")
+	}
+
+	// walking away ends the fight
+	w.teleport("testingUser", position{x: 2, y: 3})
+	w.tickBosses(now.Add(time.Second * 200))
+	if w.users[bossID].life != w.users[bossID].maxLife || w.bosses[bossID].phase != 0 {
+		t.Error("expected a boss left alone to heal and start over")
+	}
+}
+
+func TestBossFootprint(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 3)
+	w.createMonster("boss", position{x: 2, y: 3}, w.monsterTypes["minotaur"])
+	w.createUser("testingUser", 80, 20, position{x: 4, y: 4}, false)
+	w.Lock()
+	defer w.Unlock()
+	loc := &w.locations[0]
+	loc.markCells(w.footprint(w.users["boss"]), "boss")
+	w.teleport("testingUser", position{x: 4, y: 4})
+
+	// the wall to the left stops it
+	w.moveBoss("boss", position{x: 1, y: 3}, time.Now())
+	if w.users["boss"].position.x != 2 {
+		t.Fatal("expected the boss blocked by the wall")
+	}
+
+	// reaching two of its cells still hits it once
+	life := w.users["boss"].life
+	w.strikeArea("testingUser", 1, 1)
+	if got := w.users["boss"].life; got != life-1 {
+		t.Errorf("expected one hit, life went from %d to %d", life, got)
+	}
+
+	tmpUser := w.users["testingUser"]
+	tmpUser.life = 20
+	tmpUser.protectedUntil = time.Time{}
+	w.users["testingUser"] = tmpUser
+	life = w.users["boss"].life
+	w.strikeArea("boss", 1, 1)
+	if w.users["boss"].life != life || w.users["testingUser"].life != 19 {
+		t.Error("expected the boss to hit the player beside it and not itself")
+	}
+
+	if got := w.monsterIntent("boss"); got != ">attack" {
+		t.Errorf("expected the boss to attack the player beside it, got %q", got)
+	}
+}
+
+func TestLairValidation(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 1)
+	loc := &w.locations[0]
+	if _, err := loc.newLairs([]metaLair{{Name: "den", Boss: "goblin", X: 2, Y: 3}}, w.monsterTypes); err == nil {
+		t.Error("expected a lair for a monster that isn't a boss refused")
+	}
+	if _, err := loc.newLairs([]metaLair{{Name: "den", Boss: "minotaur", X: 1, Y: 1}}, w.monsterTypes); err == nil {
+		t.Error("expected a lair in a wall refused")
+	}
+	if err := (bossSpec{Size: 2, Phases: []bossPhase{{Below: 50}, {Below: 80}}}).validate(); err == nil {
+		t.Error("expected phases out of order refused")
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+const classesPath = "data/classes.json"
+
+// signature abilities, see useAbility
+const (
+	abilityWhirlwind = "whirlwind" // strikes every cell around the user
+	abilityVolley    = "volley"    // looses arrows in all four directions
+	abilityBolt      = "bolt"      // a single aimed projectile
+)
+
+// classType is a character class declared in data/classes.json
+type classType struct {
+	Name string `json:"-"`
+	// one is picked for each user of the class; the map grid is narrow so
+	// these must be too, see width.go
+	Glyphs      []string `json:"glyphs"`
+	MaxLife     int      `json:"max_life"`
+	MaxEnergy   int      `json:"max_energy"`
+	EnergyRegen int      `json:"energy_regen"`
+	Damage      int      `json:"damage"`
+	SightRadius int      `json:"sight_radius"`
+	// attack energy costs are scaled by this percentage
+	AttackCost int     `json:"attack_cost_percent"`
+	Ability    ability `json:"ability"`
+}
+
+// ability is a class's signature move
+type ability struct {
+	Kind       string `json:"kind"`
+	EnergyCost int    `json:"energy_cost"`
+	// added to the user's attack damage
+	Damage int `json:"damage"`
+	// reach of a whirlwind, or how far projectiles fly
+	Range int    `json:"range"`
+	Glyph string `json:"glyph"`
+}
+
+func loadClasses(path string) map[string]classType {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	classes := make(map[string]classType)
+	if err := json.Unmarshal(b, &classes); err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	for name, c := range classes {
+		c.Name = name
+		if len(c.Glyphs) == 0 {
+			log.Fatalf("%s: class %q has no glyphs", path, name)
+		}
+		for _, g := range c.Glyphs {
+			if stringWidth(g) != 1 {
+				log.Fatalf("%s: class %q glyph %q is not one column wide", path, name, g)
+			}
+		}
+		if c.MaxLife <= 0 || c.MaxEnergy <= 0 {
+			log.Fatalf("%s: class %q needs max_life and max_energy", path, name)
+		}
+		if c.AttackCost == 0 {
+			c.AttackCost = 100
+		}
+		switch c.Ability.Kind {
+		case abilityWhirlwind, abilityVolley, abilityBolt:
+		default:
+			log.Fatalf("%s: class %q has unknown ability %q", path, name, c.Ability.Kind)
+		}
+		if c.Ability.Glyph != "" && stringWidth(c.Ability.Glyph) != 1 {
+			log.Fatalf("%s: class %q ability glyph %q is not one column wide", path, name, c.Ability.Glyph)
+		}
+		if c.Ability.Range <= 0 {
+			c.Ability.Range = 1
+		}
+		classes[name] = c
+	}
+	return classes
+}
+
+// classNames lists the classes in a stable order
+func (wrld *world) classNames() []string {
+	names := make([]string, 0, len(wrld.classes))
+	for name := range wrld.classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyClass sets the user's stats from their class, keeping the growth
+// they have earned from levels
+func (u *user) applyClass(c classType) {
+	u.class = c.Name
+	u.character = []rune(c.Glyphs[rand.Intn(len(c.Glyphs))])[0]
+	u.maxLife = c.MaxLife + (u.level-1)*lifePerLevel
+	u.life = u.maxLife
+	u.maxEnergy = c.MaxEnergy + (u.level-1)*energyPerLevel
+	if u.energy > u.maxEnergy {
+		u.energy = u.maxEnergy
+	}
+	u.energyRegen = c.EnergyRegen + u.level/levelsPerEnergyRegen
+	u.syncRegen()
+	u.damage = c.Damage
+	if c.SightRadius > 0 {
+		u.sightRadius = c.SightRadius
+	}
+}
+
+// chooseClass lists the classes, or sets the user's class. A class is for
+// life; it can only be chosen once
+func (wrld *world) chooseClass(userID string, args []string) (string, error) {
+	names := strings.Join(wrld.classNames(), ", ")
+	if len(args) == 0 {
+		return fmt.Sprintf("classes: %s", names), nil
+	}
+	if len(args) != 1 {
+		return "", fmt.Errorf("usage: class [%s]", strings.Join(wrld.classNames(), "|"))
+	}
+	c, err := wrld.findClass(args[0])
+	if err != nil {
+		return "", err
+	}
+	tmpUser := wrld.users[userID]
+	if tmpUser.class != "" {
+		return "", fmt.Errorf("already a %s", tmpUser.class)
+	}
+	tmpUser.applyClass(c)
+	wrld.users[userID] = tmpUser
+	return fmt.Sprintf("you are now a %s", c.Name), nil
+}
+
+// findClass looks a class up by name
+func (wrld *world) findClass(name string) (classType, error) {
+	c, ok := wrld.classes[name]
+	if !ok {
+		return classType{}, fmt.Errorf("unknown class %q, choose from %s", name, strings.Join(wrld.classNames(), ", "))
+	}
+	return c, nil
+}
+
+// useAbility performs the user's signature ability. Aimed abilities take a
+// direction
+func (wrld *world) useAbility(userID string, args []string) (string, error) {
+	tmpUser := wrld.users[userID]
+	c, ok := wrld.classes[tmpUser.class]
+	if !ok {
+		return "", fmt.Errorf("choose a class first")
+	}
+	if tmpUser.hasEffect(effectStun, time.Now()) {
+		return "", fmt.Errorf("stunned")
+	}
+	if tmpUser.out {
+		return "", fmt.Errorf("out of the round")
+	}
+	ab := c.Ability
+
+	var dir point
+	if ab.Kind == abilityBolt {
+		if len(args) != 1 {
+			return "", fmt.Errorf("usage: ability <w|a|s|d>")
+		}
+		if dir, ok = attackDirections[args[0]]; !ok {
+			return "", fmt.Errorf("usage: ability <w|a|s|d>")
+		}
+	}
+
+	if tmpUser.energy < ab.EnergyCost {
+		// answered with a 200 like an attack, see attack
+		return "Not enough energy", nil
+	}
+	tmpUser.energy -= ab.EnergyCost
+	tmpUser.protectedUntil = time.Time{}
+	wrld.users[userID] = tmpUser
+
+	damage, _, _ := wrld.attackStats(tmpUser)
+	damage += ab.Damage
+	log.Printf("user %s uses %s", userID, ab.Kind)
+
+	switch ab.Kind {
+	case abilityWhirlwind:
+		wrld.strikeArea(userID, ab.Range, damage)
+	case abilityVolley:
+		for _, d := range []string{"w", "a", "s", "d"} {
+			wrld.launch(userID, attackDirections[d], ab.Range, damage, projectileGlyph(attackDirections[d]))
+		}
+	case abilityBolt:
+		glyph := projectileGlyph(dir)
+		if ab.Glyph != "" {
+			glyph = []rune(ab.Glyph)[0]
+		}
+		wrld.launch(userID, dir, ab.Range, damage, glyph)
+	}
+	return ab.Kind, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChooseClass(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 1)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+
+	if msg, err := w.chooseClass("testingUser", nil); err != nil || !strings.Contains(msg, "warrior") {
+		t.Errorf("expected the classes to be listed, got %q %v", msg, err)
+	}
+	if _, err := w.chooseClass("testingUser", []string{"bard"}); err == nil {
+		t.Error("expected an unknown class to fail")
+	}
+	if _, err := w.chooseClass("testingUser", []string{"warrior"}); err != nil {
+		t.Fatal(err)
+	}
+
+	warrior := w.classes["warrior"]
+	u := w.users["testingUser"]
+	if u.maxLife != warrior.MaxLife || u.life != warrior.MaxLife || u.damage != warrior.Damage {
+		t.Errorf("unexpected warrior stats life %d/%d damage %d", u.life, u.maxLife, u.damage)
+	}
+	if !strings.ContainsRune(strings.Join(warrior.Glyphs, ""), u.character) {
+		t.Errorf("expected a warrior glyph, got %q", u.character)
+	}
+	if _, _, cost := w.attackStats(u); cost != defaultAttackEnergy*warrior.AttackCost/100 {
+		t.Errorf("expected the warrior's attacks to cost %d%%, got %d", warrior.AttackCost, cost)
+	}
+
+	if _, err := w.chooseClass("testingUser", []string{"mage"}); err == nil {
+		t.Error("expected a class to be chosen only once")
+	}
+}
+
+func TestClassKeepsLevelGrowth(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 1)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+
+	tmpUser := w.users["testingUser"]
+	tmpUser.gainXP(xpForLevel(3))
+	w.users["testingUser"] = tmpUser
+
+	if _, err := w.chooseClass("testingUser", []string{"mage"}); err != nil {
+		t.Fatal(err)
+	}
+	mage := w.classes["mage"]
+	u := w.users["testingUser"]
+	if want := mage.MaxLife + 2*lifePerLevel; u.maxLife != want {
+		t.Errorf("expected max life %d, got %d", want, u.maxLife)
+	}
+	if want := mage.EnergyRegen + 1; u.energyRegen != want {
+		t.Errorf("expected energy regen %d, got %d", want, u.energyRegen)
+	}
+}
+
+func TestAbilities(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 3)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 4}, false)
+	w.createMonster("east", position{x: 3, y: 4}, w.monsterTypes["troll"])
+	occupy(w, "east")
+
+	if _, err := w.useAbility("testingUser", nil); err == nil {
+		t.Error("expected an ability to need a class")
+	}
+
+	w.chooseClass("testingUser", []string{"mage"})
+	if _, err := w.useAbility("testingUser", nil); err == nil {
+		t.Error("expected a bolt to need a direction")
+	}
+	tmpUser := w.users["testingUser"]
+	tmpUser.energy = tmpUser.maxEnergy
+	w.users["testingUser"] = tmpUser
+
+	if _, err := w.useAbility("testingUser", []string{"d"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := w.users["testingUser"].energy, w.users["testingUser"].maxEnergy-w.classes["mage"].Ability.EnergyCost; got != want {
+		t.Errorf("expected the bolt to cost energy, got %d want %d", got, want)
+	}
+	w.moveProjectiles()
+	damage := w.users["testingUser"].damage + w.classes["mage"].Ability.Damage
+	if got, want := w.users["east"].life, w.monsterTypes["troll"].MaxLife-damage; got != want {
+		t.Errorf("expected the bolt to hit for %d, life %d want %d", damage, got, want)
+	}
+
+	tmpUser = w.users["testingUser"]
+	tmpUser.energy = 0
+	w.users["testingUser"] = tmpUser
+	if msg, err := w.useAbility("testingUser", []string{"d"}); err != nil || msg != "Not enough energy" {
+		t.Errorf("expected abilities to need energy, answered with a 200, got %q, %v", msg, err)
+	}
+}
+
+func TestClassOnJoin(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	poll := func(query string) int {
+		rec := httptest.NewRecorder()
+		getWorld(w)(rec, httptest.NewRequest("GET", "/?w=80&h=20&"+query, nil))
+		return rec.Code
+	}
+
+	if code := poll("uid=testingUser&class=warrior"); code != 200 || w.users["testingUser"].class != "warrior" {
+		t.Fatalf("expected to join as a warrior, got %d and %q", code, w.users["testingUser"].class)
+	}
+	if code := poll("uid=testingUser&class=warrior"); code != 200 {
+		t.Errorf("expected later polls to leave the class alone, got %d", code)
+	}
+	if code := poll("uid=other&class=jester"); code != 400 {
+		t.Errorf("expected an unknown class reported, got %d", code)
+	}
+	if _, ok := w.users["other"]; ok {
+		t.Error("expected a bad class to keep the player out until they fix it")
+	}
+	if code := poll("uid=other&class=mage"); code != 200 || w.users["other"].class != "mage" {
+		t.Errorf("expected to join once the class is right, got %d and %q", code, w.users["other"].class)
+	}
+}
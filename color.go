@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+)
+
+// SGR parameters used when a client renders in color mode
+const (
+	styleNone       = ""
+	styleFog        = "2"
+	styleRemembered = "90"
+	styleHUD        = "7"
+	styleMonster    = "1;31"
+	styleSelf       = "1;7"
+	styleFlash      = "1;93;41"
+	// walls of locations whose sidecar has no wall_style
+	styleWall       = "34"
+	styleItem       = "33"
+	styleProjectile = "1;97"
+	styleNotice     = "1"
+	styleHill       = "2;33"
+	styleStorm      = "35"
+	styleStormEdge  = "1;95"
+	styleWarning    = "1;93;45"
+	styleTownsfolk  = "1;97"
+	styleGold       = "1;33"
+	// prefixed to a party or team member's own color; plain clients get
+	// allyGlyph instead
+	styleAlly = "4;"
+)
+
+// player colors deliberately leave out red, which is reserved for monsters,
+// the blue of the default walls and the yellow of items
+var playerStyles = []string{"32", "35", "36", "92", "93", "94", "95", "96"}
+
+// playerStyle picks a stable color for a user from their id
+func playerStyle(userID string) string {
+	h := fnv.New32a()
+	h.Write([]byte(userID))
+	return playerStyles[h.Sum32()%uint32(len(playerStyles))]
+}
+
+// parseColorMode maps the value a client negotiates with (?color= or the
+// `color` console command) onto whether ANSI output is wanted
+func parseColorMode(s string) (bool, error) {
+	switch s {
+	case "on", "1", "ansi", "true":
+		return true, nil
+	case "off", "0", "plain", "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("usage: color [on|off]")
+}
+
+// screen accumulates display output, emitting SGR sequences only when the
+// style changes between cells and only when color is enabled
+type screen struct {
+	color bool
+	buf   bytes.Buffer
+	cur   string
+}
+
+func (s *screen) put(r rune, style string) {
+	if s.color && style != s.cur {
+		if s.cur != styleNone {
+			s.buf.WriteString("\x1b[0m")
+		}
+		if style != styleNone {
+			s.buf.WriteString("\x1b[" + style + "m")
+		}
+		s.cur = style
+	}
+	s.buf.WriteRune(r)
+}
+
+func (s *screen) newline() {
+	if s.color && s.cur != styleNone {
+		s.buf.WriteString("\x1b[0m")
+		s.cur = styleNone
+	}
+	s.buf.WriteByte('\n')
+}
+
+func (s *screen) bytes() []byte {
+	return s.buf.Bytes()
+}
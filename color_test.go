@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestColorDisplay(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("testingUser", 40, 10, position{x: 2, y: 3}, false)
+	w.createMonster("monster", position{x: 3, y: 3}, w.monsterTypes["goblin"])
+	w.locations[0].positions["2,3"].userID = "testingUser"
+	w.locations[0].positions["3,3"].userID = "monster"
+
+	if plain := string(w.display("testingUser", 40, 10)); strings.Contains(plain, "\x1b[") {
+		t.Errorf("plain mode should not emit escape sequences: %q", plain)
+	}
+
+	tmpUser := w.users["testingUser"]
+	tmpUser.modal = loadModal("")
+	tmpUser.color = true
+	w.users["testingUser"] = tmpUser
+
+	colored := string(w.display("testingUser", 40, 10))
+	self := "\x1b[" + styleSelf + "m" + string(narrowGlyph(tmpUser.character))
+	if !strings.Contains(colored, self) {
+		t.Errorf("expected highlighted self %q in %q", self, colored)
+	}
+	monster := "\x1b[" + styleMonster + "m" + string(w.users["monster"].character)
+	if !strings.Contains(colored, monster) {
+		t.Errorf("expected red monster %q in %q", monster, colored)
+	}
+	for i, row := range strings.Split(strings.TrimSuffix(colored, "\n"), "\n") {
+		if strings.Contains(row, "\x1b[") && !strings.HasSuffix(row, "\x1b[0m") {
+			t.Errorf("row %d does not reset its style: %q", i, row)
+		}
+	}
+}
+
+func TestParseColorMode(t *testing.T) {
+	for in, want := range map[string]bool{"on": true, "ansi": true, "off": false, "plain": false} {
+		if got, err := parseColorMode(in); err != nil || got != want {
+			t.Errorf("parseColorMode(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := parseColorMode("rainbow"); err == nil {
+		t.Error("expected an error for an unknown color mode")
+	}
+}
+
+func TestWallStyle(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	if got := genWorld("maps/map_1.map", 0, 1).locations[0].wallStyle; got != styleWall {
+		t.Errorf("expected the default wall style without a sidecar, got %q", got)
+	}
+	if got := genWorld("maps/map_2.map", 0, 1).locations[0].wallStyle; got != "37" {
+		t.Errorf("expected map_2's own wall style, got %q", got)
+	}
+	for _, style := range playerStyles {
+		if style == styleWall || style == styleItem {
+			t.Errorf("players can be drawn in the wall or item color %q", style)
+		}
+	}
+}
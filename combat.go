@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// damageUser takes amount life, less what armor soaks, from the victim and,
+// if that kills them, credits the attacker and sends the victim back to the
+// start. Allies are spared unless the world has friendly fire, and pvp
+// flags, safe zones and spawn protection are respected, see canHurt. It
+// returns whether the victim died. Called with the world locked
+func (wrld *world) damageUser(attackerID, victimID string, amount int) bool {
+	victim, ok := wrld.users[victimID]
+	if !ok {
+		return false
+	}
+	if amount > 0 {
+		if !wrld.friendlyFire && wrld.allies(attackerID, victimID) {
+			return false
+		}
+		if !wrld.canHurt(attackerID, victimID, time.Now()) {
+			return false
+		}
+	}
+	dealt := wrld.soak(victim, amount)
+	wrld.hurtBoss(attackerID, victimID, dealt, time.Now())
+	victim.life -= dealt
+	wrld.users[victimID] = victim
+	if victim.life > 0 {
+		if amount > 0 {
+			wrld.onHit(attackerID, victimID)
+		}
+		return false
+	}
+
+	cell := victim.position.String()
+	log.Printf("user %s killed %s at (%s)", attackerID, victimID, cell)
+
+	// plase damaged user at start
+	// todo: if isNPC - place is non existant location?
+	{
+		tmpUser := wrld.users[victimID]
+		tmpUser.position = wrld.locations[0].randomSpawn()
+		if !tmpUser.isNPC {
+			tmpUser.protectedUntil = time.Now().Add(spawnProtection)
+		}
+		tmpUser.deaths++
+		tmpUser.life = tmpUser.maxLife
+		tmpUser.clearEffects()
+		wrld.users[victimID] = tmpUser
+	}
+	killers := []string{attackerID}
+	if shared := wrld.shareBossKill(attackerID, victim); shared != nil {
+		killers = shared
+	} else {
+		// the attacker may be a tile, or a monster that died since it fired
+		if tmpUser, ok := wrld.users[attackerID]; ok {
+			tmpUser.kills++
+			wrld.users[attackerID] = tmpUser
+		}
+		wrld.awardKillXP(attackerID, victim)
+	}
+	if victim.isNPC {
+		wrld.dropLoot(victim, cell)
+		wrld.dropGold(victim, cell)
+	}
+
+	// clear out the previous cells, a boss stands on more than one
+	wrld.locations[0].markCells(wrld.footprint(victim), "")
+	for _, id := range killers {
+		wrld.recordDeath(id, victimID, time.Now())
+	}
+	wrld.modeDeath(attackerID, victimID, victim.position)
+	return true
+}
+
+// attack spends energy on an attack. Without a direction it hits every cell
+// around the attacker; with one it strikes along that line as far as the
+// weapon reaches, or looses a projectile when the weapon is ranged
+func (wrld *world) attack(userID string, args []string) (string, error) {
+	if len(args) > 1 {
+		return "", fmt.Errorf("usage: attack [w|a|s|d]")
+	}
+	var dir point
+	if len(args) == 1 {
+		var ok bool
+		if dir, ok = attackDirections[args[0]]; !ok {
+			return "", fmt.Errorf("usage: attack [w|a|s|d]")
+		}
+	}
+
+	tmpUser := wrld.users[userID]
+	if tmpUser.hasEffect(effectStun, time.Now()) {
+		return "", fmt.Errorf("stunned")
+	}
+	if tmpUser.out {
+		return "", fmt.Errorf("out of the round")
+	}
+	damage, reach, attackEnergy := wrld.attackStats(tmpUser)
+	if tmpUser.energy < attackEnergy {
+		// not a bad request; clients have always had a 200 for this
+		return "Not enough energy", nil
+	}
+	tmpUser.energy -= attackEnergy
+	// attacking gives up spawn protection
+	tmpUser.protectedUntil = time.Time{}
+	wrld.users[userID] = tmpUser
+
+	x, y := tmpUser.position.x, tmpUser.position.y
+	log.Printf("user %s at (%d,%d) attack %v", userID, x, y, args)
+	loc := &wrld.locations[0]
+
+	if len(args) == 0 {
+		wrld.strikeArea(userID, reach, damage)
+		return "", nil
+	}
+
+	if weapon, ok := wrld.itemTypes[tmpUser.equipment[slotWeapon]]; ok && weapon.Ranged {
+		wrld.launch(userID, dir, weapon.Range, damage, projectileGlyph(dir))
+		return fmt.Sprintf("fired %s", weapon.Name), nil
+	}
+
+	hit := make(map[string]bool)
+	for i := 1; i <= reach; i++ {
+		pos, ok := loc.positions[fmt.Sprintf("%d,%d", x+dir.x*i, y+dir.y*i)]
+		if !ok || (pos.closed && pos.userID == "") {
+			// can't strike through walls
+			break
+		}
+		go areaAttack(pos)
+		// a boss covering several cells in the line is hit once
+		if pos.userID != "" && !hit[pos.userID] {
+			hit[pos.userID] = true
+			wrld.damageUser(userID, pos.userID, damage)
+		}
+	}
+	return "", nil
+}
+
+// strikeArea damages everyone within reach of the user, or of any of the
+// cells a boss covers. Each victim is hit once, however many of their cells
+// are in reach
+func (wrld *world) strikeArea(userID string, reach, damage int) {
+	x, y := wrld.users[userID].position.x, wrld.users[userID].position.y
+	size := wrld.size(wrld.users[userID])
+	hit := make(map[string]bool)
+	for i := x - reach; i < x+size+reach; i++ {
+		for j := y - reach; j < y+size+reach; j++ {
+			if !(i == x && j == y) {
+				// don't damage current user
+				if pos, ok := wrld.locations[0].positions[fmt.Sprintf("%d,%d", i, j)]; ok && pos.userID != userID {
+					go areaAttack(pos)
+					if pos.userID != "" && !hit[pos.userID] {
+						hit[pos.userID] = true
+						wrld.damageUser(userID, pos.userID, damage)
+					}
+				}
+			}
+		}
+	}
+}
+
+// onHit applies the effects the attacker's monster type or weapon carries
+func (wrld *world) onHit(attackerID, victimID string) {
+	attacker, ok := wrld.users[attackerID]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	if kind, ok := wrld.monsterTypes[attacker.kind]; ok && attacker.isNPC {
+		wrld.rollEffect(victimID, kind.OnHit, attackerID, now)
+	}
+	if weapon, ok := wrld.itemTypes[attacker.equipment[slotWeapon]]; ok {
+		wrld.rollEffect(victimID, weapon.OnHit, attackerID, now)
+	}
+}
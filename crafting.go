@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const recipesPath = "data/recipes.json"
+
+// recipeType turns components from the inventory into an item, declared in
+// data/recipes.json. Recipes that aren't known from the start are learned
+// once the player has held every component
+type recipeType struct {
+	ID    string         `json:"-"`
+	Makes string         `json:"makes"`
+	Count int            `json:"count"`
+	Needs map[string]int `json:"needs"`
+	// the kind of workstation to stand at, see tileType; "" crafts anywhere
+	Station string `json:"station"`
+	Known   bool   `json:"known"`
+}
+
+func loadRecipeTypes(path string) map[string]recipeType {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	recipes := make(map[string]recipeType)
+	if err := json.Unmarshal(b, &recipes); err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	for id, r := range recipes {
+		r.ID = id
+		if r.Count == 0 {
+			r.Count = 1
+		}
+		if r.Makes == "" || r.Count < 0 || len(r.Needs) == 0 {
+			log.Fatalf("%s: recipe %q needs what it makes and its components", path, id)
+		}
+		for item, n := range r.Needs {
+			if n <= 0 {
+				log.Fatalf("%s: recipe %q needs no %q", path, id, item)
+			}
+		}
+		recipes[id] = r
+	}
+	return recipes
+}
+
+// validateRecipes checks the items the recipes make and use exist
+func validateRecipes(recipes map[string]recipeType, items map[string]itemType) error {
+	for id, r := range recipes {
+		if _, ok := items[r.Makes]; !ok {
+			return fmt.Errorf("recipe %q makes unknown item %q", id, r.Makes)
+		}
+		for item := range r.Needs {
+			if _, ok := items[item]; !ok {
+				return fmt.Errorf("recipe %q needs unknown item %q", id, item)
+			}
+		}
+	}
+	return nil
+}
+
+// recipeIDs lists the recipes in a stable order
+func (wrld *world) recipeIDs() []string {
+	ids := make([]string, 0, len(wrld.recipeTypes))
+	for id := range wrld.recipeTypes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// knowsRecipe reports whether the player can craft the recipe
+func (wrld *world) knowsRecipe(userID, recipeID string) bool {
+	if wrld.recipeTypes[recipeID].Known {
+		return true
+	}
+	p, ok := wrld.profiles.profiles[userID]
+	if !ok {
+		return false
+	}
+	_, ok = p.Recipes[recipeID]
+	return ok
+}
+
+// learnRecipes teaches the player the recipes whose components they now
+// all hold, telling them which
+func (wrld *world) learnRecipes(userID string) {
+	u := wrld.users[userID]
+	if u.isNPC {
+		return
+	}
+	_, held := itemCounts(u.inventory)
+	for _, id := range wrld.recipeIDs() {
+		r := wrld.recipeTypes[id]
+		if wrld.knowsRecipe(userID, id) || componentsHeld(r, held) < len(r.Needs) {
+			continue
+		}
+		p := wrld.profiles.get(userID)
+		if p.Recipes == nil {
+			p.Recipes = make(map[string]time.Time)
+		}
+		p.Recipes[id] = time.Now()
+		wrld.notify(userID, fmt.Sprintf("learned to craft %s, see recipes", wrld.itemTypes[r.Makes].Name))
+	}
+}
+
+// componentsHeld counts the kinds of component of which the player holds
+// at least one
+func componentsHeld(r recipeType, held map[string]int) int {
+	n := 0
+	for item := range r.Needs {
+		if held[item] > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// missing lists the components the player is short of, "" when none
+func (wrld *world) missing(r recipeType, held map[string]int) string {
+	items := make([]string, 0, len(r.Needs))
+	for item := range r.Needs {
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	short := make([]string, 0)
+	for _, item := range items {
+		if n := r.Needs[item] - held[item]; n > 0 {
+			short = append(short, fmt.Sprintf("%s x%d", item, n))
+		}
+	}
+	return strings.Join(short, ", ")
+}
+
+// atStation reports whether a workstation of the kind is on or next to p
+func (wrld *world) atStation(p position, kind string) bool {
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			pos, ok := wrld.locations[0].positions[position{x: p.x + dx, y: p.y + dy}.String()]
+			if ok && pos.tile != nil && pos.tile.Workstation == kind {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// craft handles `craft <recipe>`, and shows the recipe book without one
+func (wrld *world) craft(userID string, args []string) (string, error) {
+	if len(args) == 0 {
+		return wrld.recipesCommand(userID)
+	}
+	if len(args) != 1 {
+		return "", fmt.Errorf("usage: craft <recipe>")
+	}
+	r, ok := wrld.recipeTypes[args[0]]
+	if !ok {
+		return "", fmt.Errorf("no recipe %q, see recipes", args[0])
+	}
+	wrld.learnRecipes(userID)
+	if !wrld.knowsRecipe(userID, r.ID) {
+		return "", fmt.Errorf("you don't know how to make %s yet", r.ID)
+	}
+	tmpUser := wrld.users[userID]
+	if r.Station != "" && !wrld.atStation(tmpUser.position, r.Station) {
+		return "", fmt.Errorf("%s is made at a %s", r.ID, r.Station)
+	}
+	_, held := itemCounts(tmpUser.inventory)
+	if short := wrld.missing(r, held); short != "" {
+		return "", fmt.Errorf("missing %s", short)
+	}
+	used := 0
+	for _, n := range r.Needs {
+		used += n
+	}
+	if len(tmpUser.inventory)-used+r.Count > inventoryCapacity {
+		return "", fmt.Errorf("inventory full (%d items)", inventoryCapacity)
+	}
+
+	for item, n := range r.Needs {
+		for i := 0; i < n; i++ {
+			tmpUser.takeItem(item)
+		}
+	}
+	for i := 0; i < r.Count; i++ {
+		tmpUser.inventory = append(tmpUser.inventory, r.Makes)
+	}
+	wrld.users[userID] = tmpUser
+	if tmpUser.activeModal == "recipes" {
+		wrld.recipesCommand(userID)
+	}
+	if r.Count > 1 {
+		return fmt.Sprintf("crafted %s x%d", wrld.itemTypes[r.Makes].Name, r.Count), nil
+	}
+	return fmt.Sprintf("crafted %s", wrld.itemTypes[r.Makes].Name), nil
+}
+
+// recipesCommand handles `recipes`: the recipe book, with what the known
+// recipes take and how close the player is to discovering the others
+func (wrld *world) recipesCommand(userID string) (string, error) {
+	wrld.learnRecipes(userID)
+	u := wrld.users[userID]
+	_, held := itemCounts(u.inventory)
+	lines := make([]string, 0)
+	undiscovered := make([]string, 0)
+	for _, id := range wrld.recipeIDs() {
+		r := wrld.recipeTypes[id]
+		if !wrld.knowsRecipe(userID, id) {
+			undiscovered = append(undiscovered, fmt.Sprintf("  ??? %d/%d components held", componentsHeld(r, held), len(r.Needs)))
+			continue
+		}
+		name := wrld.itemTypes[r.Makes].Name
+		if r.Count > 1 {
+			name = fmt.Sprintf("%s x%d", name, r.Count)
+		}
+		if wrld.missing(r, held) == "" {
+			name += " (ready)"
+		}
+		lines = append(lines, id+": "+name, "  needs "+wrld.missing(r, nil))
+		if r.Station != "" {
+			lines = append(lines, "  at a "+r.Station)
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "none known yet")
+	}
+	if len(undiscovered) > 0 {
+		lines = append(lines, "", "Undiscovered:")
+		lines = append(lines, undiscovered...)
+	}
+	lines = append(lines, "", "craft <recipe> to make one")
+
+	u.modal = loadModal(textModal("Recipe Book", lines))
+	u.activeModal = "recipes"
+	wrld.users[userID] = u
+	return "", nil
+}
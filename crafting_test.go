@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+// holding sets the user's inventory
+func holding(w *world, userID string, items ...string) {
+	tmpUser := w.users[userID]
+	tmpUser.inventory = items
+	w.users[userID] = tmpUser
+}
+
+func TestCraft(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_2.map", 0, 1)
+	w.createUser("testingUser", 80, 20, position{x: 10, y: 8}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	holding(w, "testingUser", "potion", "fang", "bone")
+	if _, err := w.craft("testingUser", []string{"salve"}); err != nil {
+		t.Fatal(err)
+	}
+	if inventory := strings.Join(w.users["testingUser"].inventory, ","); inventory != "bone,salve" {
+		t.Errorf("expected the components used up, have %s", inventory)
+	}
+
+	holding(w, "testingUser")
+	if _, err := w.craft("testingUser", []string{"elixir"}); err == nil || !strings.Contains(err.Error(), "don't know") {
+		t.Errorf("expected the elixir recipe unknown, got %v", err)
+	}
+
+	holding(w, "testingUser", "bone", "dagger", "bone")
+	if _, err := w.craft("testingUser", []string{"spear"}); err == nil || !strings.Contains(err.Error(), "forge") {
+		t.Errorf("expected the spear to need the forge, got %v", err)
+	}
+	w.teleport("testingUser", position{x: 4, y: 6})
+	if _, err := w.craft("testingUser", []string{"spear"}); err != nil {
+		t.Fatal(err)
+	}
+	if inventory := strings.Join(w.users["testingUser"].inventory, ","); inventory != "spear" {
+		t.Errorf("expected a spear, have %s", inventory)
+	}
+
+	holding(w, "testingUser", "bone", "hide")
+	if _, err := w.craft("testingUser", []string{"bow"}); err == nil || err.Error() != "missing bone x2" {
+		t.Errorf("expected two more bones needed, got %v", err)
+	}
+}
+
+func TestRecipeBook(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_2.map", 0, 1)
+	w.createUser("testingUser", 80, 20, position{x: 10, y: 8}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	holding(w, "testingUser", "potion", "potion")
+	w.recipesCommand("testingUser")
+	modal := modalText(w.users["testingUser"].modal)
+	if !strings.Contains(modal, "salve: ") || strings.Contains(modal, "elixir: ") {
+		t.Errorf("expected only the starting recipes known:\n%s", modal)
+	}
+	if !strings.Contains(modal, "??? 1/2 components held") {
+		t.Errorf("expected a hint toward the elixir:\n%s", modal)
+	}
+
+	pos := w.locations[0].positions["10,8"]
+	pos.items = append(pos.items, "quicksilver")
+	if _, err := w.pickup("testingUser"); err != nil {
+		t.Fatal(err)
+	}
+	notices := w.users["testingUser"].notices
+	if len(notices) == 0 || !strings.Contains(notices[len(notices)-1].text, "learned to craft") {
+		t.Fatalf("expected the elixir learned on picking up its last component, got %v", notices)
+	}
+	if !w.knowsRecipe("testingUser", "elixir") {
+		t.Error("expected the recipe kept in the profile")
+	}
+	holding(w, "testingUser")
+	if !w.knowsRecipe("testingUser", "elixir") {
+		t.Error("expected a learned recipe not to be forgotten")
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	flagGlyph = '⚑'
+	baseGlyph = '⌂'
+	// how close to their base a carrier has to get to capture
+	captureReach = 1
+)
+
+// metaTeam is a capture the flag team as declared in a map sidecar: where
+// its members spawn and bring flags to, and where its own flag stands
+type metaTeam struct {
+	Name  string    `json:"name"`
+	Style string    `json:"style"`
+	Base  metaPoint `json:"base"`
+	Flag  metaPoint `json:"flag"`
+}
+
+type ctfTeam struct {
+	name  string
+	style string
+	base  position
+	flag  *flag
+	score int
+}
+
+// flag is a team's flag: at home, carried by an enemy, or dropped where
+// its carrier died
+type flag struct {
+	home    position
+	at      position
+	carrier string
+}
+
+func (f *flag) atHome() bool {
+	return f.carrier == "" && f.at.String() == f.home.String()
+}
+
+func (f *flag) reset() {
+	f.at = f.home
+	f.carrier = ""
+}
+
+// captureTheFlag splits players into teams. Stepping on the enemy flag
+// picks it up, dying drops it, stepping on your own dropped flag returns
+// it, and carrying the enemy flag to your base while your own flag is home
+// scores
+type captureTheFlag struct {
+	teams      []*ctfTeam
+	scoreLimit int
+	captures   map[string]int
+}
+
+func newCaptureTheFlag(loc *location, cfg modeConfig) (*captureTheFlag, error) {
+	if len(cfg.Teams) < 2 {
+		return nil, fmt.Errorf("ctf: needs at least two teams")
+	}
+	c := &captureTheFlag{scoreLimit: cfg.ScoreLimit, captures: make(map[string]int)}
+	if c.scoreLimit <= 0 {
+		c.scoreLimit = 3
+	}
+	seen := make(map[string]bool)
+	for _, def := range cfg.Teams {
+		if def.Name == "" || seen[def.Name] {
+			return nil, fmt.Errorf("ctf: teams need distinct names")
+		}
+		seen[def.Name] = true
+		for _, p := range []metaPoint{def.Base, def.Flag} {
+			if pos, ok := loc.positions[p.position().String()]; !ok || pos.closed {
+				return nil, fmt.Errorf("ctf: team %s has its base or flag at (%d,%d), which is not an open cell", def.Name, p.X, p.Y)
+			}
+		}
+		style := def.Style
+		if style == "" {
+			style = playerStyle(def.Name)
+		}
+		home := def.Flag.position()
+		c.teams = append(c.teams, &ctfTeam{
+			name:  def.Name,
+			style: style,
+			base:  def.Base.position(),
+			flag:  &flag{home: home, at: home},
+		})
+	}
+	return c, nil
+}
+
+func (c *captureTheFlag) Name() string { return "capture the flag" }
+
+func (c *captureTheFlag) Goal() string {
+	return fmt.Sprintf("bring the enemy flag to your base %d times", c.scoreLimit)
+}
+
+func (c *captureTheFlag) PvP() bool { return true }
+
+func (c *captureTheFlag) team(name string) *ctfTeam {
+	for _, t := range c.teams {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Start puts the flags home and clears the teams, which are dealt out
+// afresh as everyone joins
+func (c *captureTheFlag) Start(wrld *world, now time.Time) {
+	c.captures = make(map[string]int)
+	for _, t := range c.teams {
+		t.score = 0
+		t.flag.reset()
+	}
+	for _, id := range wrld.players() {
+		tmpUser := wrld.users[id]
+		tmpUser.team = ""
+		wrld.users[id] = tmpUser
+	}
+}
+
+// Join puts the player on the smallest team and sends them to its base
+func (c *captureTheFlag) Join(wrld *world, userID string) {
+	sizes := make(map[string]int)
+	for _, u := range wrld.users {
+		if !u.isNPC {
+			sizes[u.team]++
+		}
+	}
+	smallest := c.teams[0]
+	for _, t := range c.teams[1:] {
+		if sizes[t.name] < sizes[smallest.name] {
+			smallest = t
+		}
+	}
+
+	tmpUser := wrld.users[userID]
+	tmpUser.team = smallest.name
+	wrld.users[userID] = tmpUser
+	wrld.teleport(userID, smallest.base)
+	wrld.notify(userID, fmt.Sprintf("you are on team %s", smallest.name))
+}
+
+func (c *captureTheFlag) Tick(wrld *world, now time.Time) {
+	for _, t := range c.teams {
+		f := t.flag
+		if f.carrier != "" {
+			carrier, ok := wrld.users[f.carrier]
+			if !ok {
+				// the carrier left the world
+				f.carrier = ""
+				continue
+			}
+			f.at = carrier.position
+			if own := c.team(carrier.team); own != nil && own.flag.atHome() &&
+				abs(f.at.x-own.base.x) <= captureReach && abs(f.at.y-own.base.y) <= captureReach {
+				own.score++
+				c.captures[f.carrier]++
+				wrld.announce(fmt.Sprintf("%s captured the %s flag, %s", f.carrier, t.name, c.scoreLine()))
+				f.reset()
+			}
+			continue
+		}
+
+		for _, id := range wrld.players() {
+			u := wrld.users[id]
+			if u.position.String() != f.at.String() || u.team == "" {
+				continue
+			}
+			if u.team != t.name {
+				f.carrier = id
+				wrld.announce(fmt.Sprintf("%s took the %s flag", id, t.name))
+				break
+			}
+			if !f.atHome() {
+				f.reset()
+				wrld.announce(fmt.Sprintf("%s returned the %s flag", id, t.name))
+				break
+			}
+		}
+	}
+}
+
+// Death drops whatever flag the victim carried and respawns players at
+// their team's base
+func (c *captureTheFlag) Death(wrld *world, attackerID, victimID string, where position) {
+	for _, t := range c.teams {
+		if t.flag.carrier == victimID {
+			t.flag.carrier = ""
+			t.flag.at = where
+			wrld.announce(fmt.Sprintf("%s dropped the %s flag", victimID, t.name))
+		}
+	}
+	if t := c.team(wrld.users[victimID].team); t != nil {
+		wrld.teleport(victimID, t.base)
+	}
+}
+
+func (c *captureTheFlag) Over(wrld *world, now time.Time) bool {
+	for _, t := range c.teams {
+		if t.score >= c.scoreLimit {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *captureTheFlag) Results(wrld *world) (string, []string) {
+	scores := make(map[string]int)
+	for _, t := range c.teams {
+		scores[t.name] = t.score
+	}
+	winner, standings := rank(scores)
+	lines := make([]string, 0)
+	for _, s := range standings {
+		lines = append(lines, fmt.Sprintf("%-20s %5d", s.name, s.score))
+	}
+	if len(c.captures) > 0 {
+		_, carriers := rank(c.captures)
+		lines = append(lines, "")
+		for _, s := range carriers {
+			lines = append(lines, fmt.Sprintf("%-20s %5d", s.name, s.score))
+		}
+	}
+	return winner, lines
+}
+
+func (c *captureTheFlag) scoreLine() string {
+	parts := make([]string, 0, len(c.teams))
+	for _, t := range c.teams {
+		parts = append(parts, fmt.Sprintf("%s %d", t.name, t.score))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (c *captureTheFlag) Status(wrld *world, userID string) string {
+	team := wrld.users[userID].team
+	if team == "" {
+		return c.scoreLine()
+	}
+	return fmt.Sprintf("[%s] %s", team, c.scoreLine())
+}
+
+// Glyph draws loose flags, and bases where no flag lies
+func (c *captureTheFlag) Glyph(wrld *world, cell string) (rune, string, bool) {
+	for _, t := range c.teams {
+		if t.flag.carrier == "" && t.flag.at.String() == cell {
+			return flagGlyph, t.style, true
+		}
+	}
+	for _, t := range c.teams {
+		if t.base.String() == cell {
+			return baseGlyph, t.style, true
+		}
+	}
+	return 0, styleNone, false
+}
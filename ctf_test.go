@@ -0,0 +1,144 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCaptureTheFlag(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("Alice", 80, 20, position{x: 2, y: 3}, false)
+	w.createUser("Bob", 80, 20, position{x: 3, y: 3}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	mode, err := w.newGameMode("ctf", modeConfig{ScoreLimit: 1, Teams: []metaTeam{
+		{Name: "red", Base: metaPoint{X: 2, Y: 4}, Flag: metaPoint{X: 4, Y: 4}},
+		{Name: "blue", Base: metaPoint{X: 14, Y: 4}, Flag: metaPoint{X: 12, Y: 4}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.setMode(mode, time.Minute)
+	now := time.Now()
+	w.tickMode(now)
+
+	if w.users["Alice"].team != "red" || w.users["Bob"].team != "blue" {
+		t.Fatalf("expected the players split into teams, got %q and %q", w.users["Alice"].team, w.users["Bob"].team)
+	}
+	if got := w.users["Bob"].position; abs(got.x-14) > 1 || abs(got.y-4) > 1 {
+		t.Errorf("expected Bob sent to the blue base, at %s", got)
+	}
+	for _, id := range []string{"Alice", "Bob"} {
+		tmpUser := w.users[id]
+		tmpUser.protectedUntil = time.Time{}
+		w.users[id] = tmpUser
+	}
+	if !w.canHurt("Bob", "Alice", now) {
+		t.Error("expected teams to fight without pvp on")
+	}
+
+	ctf := mode.(*captureTheFlag)
+	blue := ctf.team("blue").flag
+	w.teleport("Alice", position{x: 12, y: 4})
+	w.tickMode(now)
+	if blue.carrier != "Alice" {
+		t.Fatal("expected Alice to pick up the blue flag")
+	}
+
+	w.teleport("Alice", position{x: 10, y: 4})
+	w.tickMode(now)
+	w.damageUser("Bob", "Alice", 100)
+	if blue.carrier != "" || blue.at.String() != "10,4" {
+		t.Errorf("expected the flag dropped where Alice died, at %s carried by %q", blue.at, blue.carrier)
+	}
+	if got := w.users["Alice"].position; abs(got.x-2) > 1 || abs(got.y-4) > 1 {
+		t.Errorf("expected Alice to respawn at the red base, at %s", got)
+	}
+
+	w.teleport("Bob", position{x: 10, y: 4})
+	w.tickMode(now)
+	if !blue.atHome() {
+		t.Error("expected Bob to return the blue flag")
+	}
+
+	w.teleport("Alice", position{x: 12, y: 4})
+	w.tickMode(now)
+	w.teleport("Alice", position{x: 3, y: 4})
+	w.tickMode(now)
+	if got := ctf.team("red").score; got != 1 {
+		t.Fatalf("expected red to score, got %d", got)
+	}
+	if w.round.running {
+		t.Fatal("expected the round to end at the score limit")
+	}
+	if !strings.Contains(w.round.results, "Winner: red") {
+		t.Errorf("expected red to win:\n%s", w.round.results)
+	}
+
+	// between rounds the teams are gone and the pvp flags decide again
+	fightable(w, "Alice", "Bob")
+	tmpUser := w.users["Bob"]
+	tmpUser.pvp = false
+	w.users["Bob"] = tmpUser
+	if w.users["Alice"].team != "" || w.users["Bob"].team != "" {
+		t.Error("expected the teams cleared when the round ends")
+	}
+	if w.canHurt("Alice", "Bob", now) {
+		t.Error("expected pvp off to be respected between rounds")
+	}
+}
+
+func TestJoinMidRound(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.Lock()
+	mode, err := w.newGameMode("ctf", modeConfig{ScoreLimit: 1, Teams: []metaTeam{
+		{Name: "red", Base: metaPoint{X: 2, Y: 4}, Flag: metaPoint{X: 4, Y: 4}},
+		{Name: "blue", Base: metaPoint{X: 14, Y: 4}, Flag: metaPoint{X: 12, Y: 4}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.setMode(mode, time.Minute)
+	w.tickMode(time.Now())
+	w.Unlock()
+
+	// joining takes the world lock itself
+	getWorld(w)(httptest.NewRecorder(), httptest.NewRequest("GET", "/?w=80&h=20&uid=Carol", nil))
+	w.Lock()
+	defer w.Unlock()
+	if w.users["Carol"].team == "" {
+		t.Error("expected a player joining mid-round to be put on a team")
+	}
+}
+
+func TestFlagGlyphs(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 1)
+	mode, err := w.newGameMode("ctf", modeConfig{Teams: []metaTeam{
+		{Name: "red", Base: metaPoint{X: 2, Y: 4}, Flag: metaPoint{X: 4, Y: 4}},
+		{Name: "blue", Base: metaPoint{X: 14, Y: 4}, Flag: metaPoint{X: 12, Y: 4}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, ok := mode.Glyph(w, "4,4"); !ok || r != flagGlyph {
+		t.Error("expected the red flag drawn at home")
+	}
+	if r, _, ok := mode.Glyph(w, "14,4"); !ok || r != baseGlyph {
+		t.Error("expected the blue base drawn")
+	}
+
+	if _, err := w.newGameMode("ctf", modeConfig{Teams: []metaTeam{
+		{Name: "red", Base: metaPoint{X: 1, Y: 1}, Flag: metaPoint{X: 4, Y: 4}},
+		{Name: "blue", Base: metaPoint{X: 14, Y: 4}, Flag: metaPoint{X: 12, Y: 4}},
+	}}); err == nil {
+		t.Error("expected a base in a wall to be refused")
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"sort"
+)
+
+const shopsPath = "data/shops.json"
+
+// shopType is what a shopkeeper trades in, declared in data/shops.json.
+// Prices are in gold
+type shopType struct {
+	ID    string         `json:"-"`
+	Name  string         `json:"name"`
+	Sells map[string]int `json:"sells"`
+	Buys  map[string]int `json:"buys"`
+}
+
+func loadShopTypes(path string) map[string]shopType {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	shops := make(map[string]shopType)
+	if err := json.Unmarshal(b, &shops); err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	for id, s := range shops {
+		s.ID = id
+		if s.Name == "" {
+			s.Name = id
+		}
+		for _, prices := range []map[string]int{s.Sells, s.Buys} {
+			for item, price := range prices {
+				if price <= 0 {
+					log.Fatalf("%s: shop %q has no price for %q", path, id, item)
+				}
+			}
+		}
+		shops[id] = s
+	}
+	return shops
+}
+
+// dropGold leaves the monster's gold on the cell it died on
+func (wrld *world) dropGold(victim user, cell string) {
+	kind, ok := wrld.monsterTypes[victim.kind]
+	if !ok || kind.Gold <= 0 {
+		return
+	}
+	if pos, ok := wrld.locations[0].positions[cell]; ok {
+		pos.gold += 1 + rand.Intn(kind.Gold)
+	}
+}
+
+// collectGold picks up the gold on the player's cell, reporting how much
+func (wrld *world) collectGold(userID string) int {
+	tmpUser := wrld.users[userID]
+	pos, ok := wrld.locations[0].positions[tmpUser.position.String()]
+	if !ok || pos.gold == 0 || tmpUser.isNPC {
+		return 0
+	}
+	gold := pos.gold
+	pos.gold = 0
+	tmpUser.gold += gold
+	wrld.users[userID] = tmpUser
+	return gold
+}
+
+// shopBeside returns the shop of the shopkeeper next to the user
+func (wrld *world) shopBeside(userID string) (shopType, error) {
+	npcID := wrld.npcBeside(userID)
+	if npcID == "" || wrld.users[npcID].role != roleShopkeeper {
+		return shopType{}, fmt.Errorf("no shopkeeper here, stand next to one")
+	}
+	return wrld.shopTypes[wrld.users[npcID].shop], nil
+}
+
+// shopTalk shows what the shopkeeper sells and buys
+func (wrld *world) shopTalk(userID, npcID string) (string, error) {
+	shop := wrld.shopTypes[wrld.users[npcID].shop]
+	tmpUser := wrld.users[userID]
+	tmpUser.modal = loadModal(wrld.shopModal(shop, tmpUser))
+	tmpUser.activeModal = "shop"
+	wrld.users[userID] = tmpUser
+	return "", nil
+}
+
+func (wrld *world) shopModal(shop shopType, u user) string {
+	lines := []string{"Sells, buy <item>:"}
+	lines = append(lines, wrld.priceRows(shop.Sells)...)
+	lines = append(lines, "", "Buys, sell <item>:")
+	lines = append(lines, wrld.priceRows(shop.Buys)...)
+	lines = append(lines, "", fmt.Sprintf("Your gold: %d", u.gold))
+	return textModal(shop.Name, lines)
+}
+
+func (wrld *world) priceRows(prices map[string]int) []string {
+	ids := make([]string, 0, len(prices))
+	for id := range prices {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	rows := make([]string, 0, len(ids))
+	for _, id := range ids {
+		rows = append(rows, fmt.Sprintf("  %-10.10s %-16.16s %4d", id, wrld.itemTypes[id].Name, prices[id]))
+	}
+	if len(rows) == 0 {
+		rows = append(rows, "  nothing")
+	}
+	return rows
+}
+
+// buy handles `buy <item>` from the shopkeeper next to the user
+func (wrld *world) buy(userID string, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("usage: buy <item>")
+	}
+	shop, err := wrld.shopBeside(userID)
+	if err != nil {
+		return "", err
+	}
+	price, ok := shop.Sells[args[0]]
+	if !ok {
+		return "", fmt.Errorf("the %s doesn't sell %s", shop.Name, args[0])
+	}
+	tmpUser := wrld.users[userID]
+	if tmpUser.gold < price {
+		return "", fmt.Errorf("%s costs %d gold, you have %d", args[0], price, tmpUser.gold)
+	}
+	if len(tmpUser.inventory) >= inventoryCapacity {
+		return "", fmt.Errorf("inventory full (%d items)", inventoryCapacity)
+	}
+	tmpUser.gold -= price
+	tmpUser.inventory = append(tmpUser.inventory, args[0])
+	if tmpUser.activeModal == "shop" {
+		tmpUser.modal = loadModal(wrld.shopModal(shop, tmpUser))
+	}
+	wrld.users[userID] = tmpUser
+	return fmt.Sprintf("bought %s for %d gold", wrld.itemTypes[args[0]].Name, price), nil
+}
+
+// sell handles `sell <item>` to the shopkeeper next to the user
+func (wrld *world) sell(userID string, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("usage: sell <item>")
+	}
+	shop, err := wrld.shopBeside(userID)
+	if err != nil {
+		return "", err
+	}
+	price, ok := shop.Buys[args[0]]
+	if !ok {
+		return "", fmt.Errorf("the %s doesn't buy %s", shop.Name, args[0])
+	}
+	tmpUser := wrld.users[userID]
+	if !tmpUser.takeItem(args[0]) {
+		return "", fmt.Errorf("no %s in inventory", args[0])
+	}
+	tmpUser.gold += price
+	if tmpUser.activeModal == "shop" {
+		tmpUser.modal = loadModal(wrld.shopModal(shop, tmpUser))
+	}
+	wrld.users[userID] = tmpUser
+	return fmt.Sprintf("sold %s for %d gold", wrld.itemTypes[args[0]].Name, price), nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestGoldDrop(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+	w.createMonster("goblin", position{x: 2, y: 4}, w.monsterTypes["goblin"])
+	w.Lock()
+	defer w.Unlock()
+
+	if !w.damageUser("testingUser", "goblin", 100) {
+		t.Fatal("expected the goblin to die")
+	}
+	pos := w.locations[0].positions["2,4"]
+	gold := pos.gold
+	if gold < 1 || gold > w.monsterTypes["goblin"].Gold {
+		t.Fatalf("expected up to %d gold dropped, got %d", w.monsterTypes["goblin"].Gold, gold)
+	}
+	pos.items = nil
+
+	tmpUser := w.users["testingUser"]
+	tmpUser.modal = loadModal("")
+	w.users["testingUser"] = tmpUser
+	if !strings.Contains(string(w.display("testingUser", 80, 20)), "$") {
+		t.Error("expected the gold to be drawn")
+	}
+	w.teleport("testingUser", position{x: 2, y: 4})
+	if _, err := w.pickup("testingUser"); err != nil {
+		t.Fatal(err)
+	}
+	if w.users["testingUser"].gold != gold || pos.gold != 0 {
+		t.Errorf("expected %d gold picked up, have %d with %d left", gold, w.users["testingUser"].gold, pos.gold)
+	}
+}
+
+func TestShopkeeper(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_2.map", 0, 1)
+	w.createUser("testingUser", 80, 20, position{x: 10, y: 8}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	if _, err := w.buy("testingUser", []string{"potion"}); err == nil {
+		t.Error("expected no shop away from the merchant")
+	}
+
+	w.teleport("testingUser", position{x: 2, y: 5})
+	if _, err := w.talk("testingUser", nil); err != nil {
+		t.Fatal(err)
+	}
+	if modal := modalText(w.users["testingUser"].modal); !strings.Contains(modal, "general store") {
+		t.Errorf("expected the shop modal:\n%s", modal)
+	}
+
+	tmpUser := w.users["testingUser"]
+	tmpUser.inventory = nil
+	tmpUser.gold = 5
+	w.users["testingUser"] = tmpUser
+	if _, err := w.buy("testingUser", []string{"potion"}); err == nil {
+		t.Error("expected a potion to cost more than 5 gold")
+	}
+	tmpUser = w.users["testingUser"]
+	tmpUser.gold = 25
+	w.users["testingUser"] = tmpUser
+	if _, err := w.buy("testingUser", []string{"potion"}); err != nil {
+		t.Fatal(err)
+	}
+	if u := w.users["testingUser"]; u.gold != 15 || strings.Join(u.inventory, ",") != "potion" {
+		t.Errorf("expected a potion for 10 gold, have %d gold and %v", u.gold, u.inventory)
+	}
+	if _, err := w.buy("testingUser", []string{"sword"}); err == nil {
+		t.Error("expected swords not to be sold")
+	}
+
+	if _, err := w.sell("testingUser", []string{"bone"}); err == nil {
+		t.Error("expected nothing sold that isn't held")
+	}
+	if _, err := w.sell("testingUser", []string{"potion"}); err != nil {
+		t.Fatal(err)
+	}
+	if u := w.users["testingUser"]; u.gold != 19 || len(u.inventory) != 0 {
+		t.Errorf("expected the potion sold back for 4 gold, have %d gold and %v", u.gold, u.inventory)
+	}
+	if modal := modalText(w.users["testingUser"].modal); !strings.Contains(modal, "Your gold: 19") {
+		t.Errorf("expected the shop modal to follow the purse:\n%s", modal)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// status effects
+const (
+	effectPoison   = "poison"   // loses magnitude life every tick
+	effectStun     = "stun"     // can't move, attack or use abilities
+	effectHaste    = "haste"    // attacks cost half energy, monsters act twice as fast
+	effectRegen    = "regen"    // gains magnitude life every tick
+	effectRecharge = "recharge" // gains magnitude energy every tick
+)
+
+// stacking rules, for when an effect is applied to a user who already has it
+const (
+	// one instance, extended to the longer duration and the larger magnitude
+	stackRefresh = "refresh"
+	// separate instances up to maxStacks; past that the one closest to
+	// running out is replaced
+	stackAdd = "add"
+)
+
+const (
+	maxStacks = 3
+
+	energyRechargeEvery = time.Millisecond * 500
+)
+
+// effectKind is how an effect behaves
+type effectKind struct {
+	// time between ticks, see tickEffect, or 0 for effects that only
+	// change how other things work while they last
+	every time.Duration
+	stack string
+	// short name shown in the HUD
+	tag string
+}
+
+var effectKinds = map[string]effectKind{
+	effectPoison:   {every: time.Second, stack: stackAdd, tag: "psn"},
+	effectStun:     {stack: stackRefresh, tag: "stn"},
+	effectHaste:    {stack: stackRefresh, tag: "hst"},
+	effectRegen:    {every: time.Second * 2, stack: stackAdd, tag: "rgn"},
+	effectRecharge: {every: energyRechargeEvery, stack: stackAdd, tag: "rch"},
+}
+
+// effect is an effect on a user
+type effect struct {
+	kind      string
+	magnitude int
+	every     time.Duration
+	next      time.Time
+	// zero for permanent effects, which last until removed
+	until time.Time
+	// who caused it, credited if it kills
+	source string
+}
+
+func (e *effect) permanent() bool {
+	return e.until.IsZero()
+}
+
+// effectSpec is how an effect is declared in data files: what an item does
+// when used, what an attack does on hit, or what a tile does when stepped on
+type effectSpec struct {
+	Kind      string `json:"kind"`
+	Seconds   int    `json:"seconds"`
+	Magnitude int    `json:"magnitude"`
+	// percent chance of applying on hit, 0 meaning always
+	Chance int `json:"chance"`
+}
+
+func (s effectSpec) validate() error {
+	if _, ok := effectKinds[s.Kind]; !ok {
+		return fmt.Errorf("unknown effect %q", s.Kind)
+	}
+	if s.Seconds <= 0 {
+		return fmt.Errorf("effect %q needs seconds", s.Kind)
+	}
+	return nil
+}
+
+// baseEffects are the permanent effects everyone starts with: regenerating
+// life and energy at the user's own rates
+func (u *user) baseEffects() []*effect {
+	return []*effect{
+		{kind: effectRegen, magnitude: 1, every: u.lifeRegen},
+		{kind: effectRecharge, magnitude: u.energyRegen, every: energyRechargeEvery},
+	}
+}
+
+// syncRegen brings the permanent regen effects in line with the user's
+// regen rates after they change
+func (u *user) syncRegen() {
+	for _, e := range u.effects {
+		if !e.permanent() {
+			continue
+		}
+		switch e.kind {
+		case effectRegen:
+			e.every = u.lifeRegen
+		case effectRecharge:
+			e.magnitude = u.energyRegen
+		}
+	}
+}
+
+// hasEffect reports whether an effect of the kind is active
+func (u user) hasEffect(kind string, now time.Time) bool {
+	for _, e := range u.effects {
+		if e.kind == kind && (e.permanent() || now.Before(e.until)) {
+			return true
+		}
+	}
+	return false
+}
+
+// addEffect applies an effect following its kind's stacking rule
+func (wrld *world) addEffect(userID string, spec effectSpec, source string, now time.Time) {
+	tmpUser, ok := wrld.users[userID]
+	if !ok {
+		return
+	}
+	kind := effectKinds[spec.Kind]
+	e := &effect{
+		kind:      spec.Kind,
+		magnitude: spec.Magnitude,
+		every:     kind.every,
+		next:      now.Add(kind.every),
+		until:     now.Add(time.Duration(spec.Seconds) * time.Second),
+		source:    source,
+	}
+	if e.magnitude == 0 {
+		e.magnitude = 1
+	}
+
+	var same []*effect
+	for _, old := range tmpUser.effects {
+		if old.kind == spec.Kind && !old.permanent() {
+			same = append(same, old)
+		}
+	}
+	switch {
+	case len(same) == 0 || (kind.stack == stackAdd && len(same) < maxStacks):
+		tmpUser.effects = append(tmpUser.effects, e)
+	case kind.stack == stackRefresh:
+		old := same[0]
+		if e.until.After(old.until) {
+			old.until = e.until
+		}
+		if e.magnitude > old.magnitude {
+			old.magnitude = e.magnitude
+		}
+		old.source = source
+	default:
+		sort.Slice(same, func(i, j int) bool { return same[i].until.Before(same[j].until) })
+		*same[0] = *e
+	}
+	wrld.users[userID] = tmpUser
+	log.Printf("user %s gets %s from %s", userID, spec.Kind, source)
+}
+
+// rollEffect applies an on-hit effect if its chance comes up
+func (wrld *world) rollEffect(userID string, spec *effectSpec, source string, now time.Time) {
+	if spec == nil || (spec.Chance > 0 && rand.Intn(100) >= spec.Chance) {
+		return
+	}
+	wrld.addEffect(userID, *spec, source, now)
+}
+
+// clearEffects removes every timed effect, as dying does
+func (u *user) clearEffects() {
+	kept := make([]*effect, 0, len(u.effects))
+	for _, e := range u.effects {
+		if e.permanent() {
+			kept = append(kept, e)
+		}
+	}
+	u.effects = kept
+}
+
+// tickEffects drops expired effects and ticks the rest that are due. It is
+// called from the game loop with the world locked
+func (wrld *world) tickEffects(now time.Time) {
+	for userID := range wrld.users {
+		kept := make([]*effect, 0, len(wrld.users[userID].effects))
+		died := false
+		for _, e := range wrld.users[userID].effects {
+			if !e.permanent() && !now.Before(e.until) {
+				continue
+			}
+			kept = append(kept, e)
+			if e.every <= 0 || now.Before(e.next) {
+				continue
+			}
+			e.next = now.Add(e.every)
+			if wrld.tickEffect(userID, e) {
+				// dying cleared their effects already
+				died = true
+				break
+			}
+		}
+		if died {
+			continue
+		}
+		tmpUser := wrld.users[userID]
+		tmpUser.effects = kept
+		wrld.users[userID] = tmpUser
+	}
+}
+
+// effectSummary lists the timed effects with their time left, for the
+// profile, or just their tags, for the HUD
+func (u *user) effectSummary(now time.Time, tags bool) string {
+	parts := make([]string, 0, len(u.effects))
+	for _, e := range u.effects {
+		if e.permanent() || !now.Before(e.until) {
+			continue
+		}
+		if tags {
+			parts = append(parts, effectKinds[e.kind].tag)
+		} else {
+			parts = append(parts, fmt.Sprintf("%s %ds", e.kind, int(e.until.Sub(now).Seconds()+0.5)))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// tickEffect applies an effect once. It reports whether the user died
+func (wrld *world) tickEffect(userID string, e *effect) bool {
+	tmpUser := wrld.users[userID]
+	switch e.kind {
+	case effectPoison:
+		if wrld.sheltered(userID, time.Now()) {
+			return false
+		}
+		tmpUser.life -= e.magnitude
+		wrld.users[userID] = tmpUser
+		if tmpUser.life > 0 {
+			return false
+		}
+		// poison goes around armor, so only let damageUser handle the death
+		return wrld.damageUser(e.source, userID, 0)
+	case effectRegen:
+		if tmpUser.life < tmpUser.maxLife {
+			tmpUser.life += e.magnitude
+			if tmpUser.life > tmpUser.maxLife {
+				tmpUser.life = tmpUser.maxLife
+			}
+		}
+	case effectRecharge:
+		if tmpUser.energy < tmpUser.maxEnergy {
+			tmpUser.energy += e.magnitude
+			if tmpUser.energy > tmpUser.maxEnergy {
+				tmpUser.energy = tmpUser.maxEnergy
+			}
+		}
+	}
+	wrld.users[userID] = tmpUser
+	return false
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPermanentRegen(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 1)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	tmpUser := w.users["testingUser"]
+	tmpUser.life = 1
+	w.users["testingUser"] = tmpUser
+	energy := tmpUser.energy
+
+	now := time.Now()
+	w.tickEffects(now)
+	if got := w.users["testingUser"].life; got != 2 {
+		t.Errorf("expected a life regen tick, life %d", got)
+	}
+	if got := w.users["testingUser"].energy; got != energy+1 {
+		t.Errorf("expected an energy regen tick, energy %d", got)
+	}
+
+	w.tickEffects(now.Add(time.Second))
+	if got := w.users["testingUser"].life; got != 2 {
+		t.Errorf("expected life regen to wait, life %d", got)
+	}
+	w.tickEffects(now.Add(baseLifeRegen))
+	if got := w.users["testingUser"].life; got != 3 {
+		t.Errorf("expected a second life regen tick, life %d", got)
+	}
+
+	// leveling up speeds regen up
+	tmpUser = w.users["testingUser"]
+	tmpUser.gainXP(xpForLevel(2))
+	w.users["testingUser"] = tmpUser
+	for _, e := range w.users["testingUser"].effects {
+		if e.kind == effectRegen && e.every != w.users["testingUser"].lifeRegen {
+			t.Errorf("expected regen every %s, got %s", w.users["testingUser"].lifeRegen, e.every)
+		}
+	}
+}
+
+func TestPoisonKills(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+	w.createMonster("rat", position{x: 2, y: 4}, w.monsterTypes["rat"])
+	fightable(w, "testingUser")
+	w.Lock()
+	defer w.Unlock()
+
+	now := time.Now()
+	w.addEffect("testingUser", effectSpec{Kind: effectPoison, Seconds: 10, Magnitude: 1}, "rat", now)
+	if !w.users["testingUser"].hasEffect(effectPoison, now) {
+		t.Fatal("expected the user to be poisoned")
+	}
+
+	life := w.users["testingUser"].life
+	for i := 1; i < life; i++ {
+		w.tickEffects(now.Add(time.Duration(i) * time.Second))
+	}
+	if got := w.users["testingUser"].life; got != 1 {
+		t.Fatalf("expected poison to take a life a second, life %d", got)
+	}
+	w.tickEffects(now.Add(time.Duration(life) * time.Second))
+	if got := w.users["testingUser"].deaths; got != 1 {
+		t.Errorf("expected the poison to kill, deaths %d", got)
+	}
+	if got := w.users["rat"].kills; got != 1 {
+		t.Errorf("expected the rat credited with the kill, kills %d", got)
+	}
+	if w.users["testingUser"].hasEffect(effectPoison, now) {
+		t.Error("expected dying to cure poison")
+	}
+	if !w.users["testingUser"].hasEffect(effectRegen, now) {
+		t.Error("expected dying to keep permanent regen")
+	}
+}
+
+func TestEffectStacking(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 1)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	count := func(kind string) int {
+		n := 0
+		for _, e := range w.users["testingUser"].effects {
+			if e.kind == kind {
+				n++
+			}
+		}
+		return n
+	}
+
+	now := time.Now()
+	for i := 0; i < maxStacks+2; i++ {
+		w.addEffect("testingUser", effectSpec{Kind: effectPoison, Seconds: 5}, "", now)
+	}
+	if got := count(effectPoison); got != maxStacks {
+		t.Errorf("expected poison to stack to %d, got %d", maxStacks, got)
+	}
+
+	w.addEffect("testingUser", effectSpec{Kind: effectHaste, Seconds: 5}, "", now)
+	w.addEffect("testingUser", effectSpec{Kind: effectHaste, Seconds: 10}, "", now)
+	if got := count(effectHaste); got != 1 {
+		t.Errorf("expected haste to refresh rather than stack, got %d", got)
+	}
+	if !w.users["testingUser"].hasEffect(effectHaste, now.Add(time.Second*8)) {
+		t.Error("expected haste refreshed to the longer duration")
+	}
+
+	w.tickEffects(now.Add(time.Second * 11))
+	if got := count(effectHaste) + count(effectPoison); got != 0 {
+		t.Errorf("expected effects to expire, %d left", got)
+	}
+}
+
+func TestStunAndHaste(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 1)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	now := time.Now()
+	_, _, cost := w.attackStats(w.users["testingUser"])
+	w.addEffect("testingUser", effectSpec{Kind: effectHaste, Seconds: 5}, "", now)
+	if _, _, hasted := w.attackStats(w.users["testingUser"]); hasted != cost/2 {
+		t.Errorf("expected haste to halve attack cost, got %d want %d", hasted, cost/2)
+	}
+
+	w.addEffect("testingUser", effectSpec{Kind: effectStun, Seconds: 5}, "", now)
+	if _, err := w.attack("testingUser", nil); err == nil {
+		t.Error("expected a stunned user not to attack")
+	}
+
+	result := make(chan commandStatus, 1)
+	w.commands = append(w.commands, command{cmd: "md", userID: "testingUser", result: result})
+	w.Unlock()
+	w.updateBoard()
+	w.Lock()
+	if got := w.users["testingUser"].position.x; got != 2 {
+		t.Errorf("expected a stunned user not to move, x %d", got)
+	}
+}
+
+func TestEffectSources(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 1)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	tmpUser := w.users["testingUser"]
+	tmpUser.inventory = []string{"salve"}
+	w.users["testingUser"] = tmpUser
+	if _, err := w.use("testingUser", []string{"salve"}); err != nil {
+		t.Fatal(err)
+	}
+	if !w.users["testingUser"].hasEffect(effectRegen, time.Now()) {
+		t.Error("expected the salve to regenerate")
+	}
+	if !strings.Contains(w.profileModal("testingUser"), "regen") {
+		t.Error("expected the effect in the profile")
+	}
+	u := w.users["testingUser"]
+	if !strings.Contains(string(u.hudLine(&w.locations[0], 200, "")), "rgn") {
+		t.Error("expected the effect in the HUD")
+	}
+
+	// tiles apply their effect to whoever steps on them
+	w.locations[0].positions["3,3"].effect = &effectSpec{Kind: effectPoison, Seconds: 3}
+	result := make(chan commandStatus, 1)
+	w.commands = append(w.commands, command{cmd: "md", userID: "testingUser", result: result})
+	w.Unlock()
+	w.updateBoard()
+	w.Lock()
+	if !w.users["testingUser"].hasEffect(effectPoison, time.Now()) {
+		t.Error("expected the tile to poison")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// equipment slots
+const (
+	slotWeapon  = "weapon"
+	slotArmor   = "armor"
+	slotTrinket = "trinket"
+)
+
+var slots = []string{slotWeapon, slotArmor, slotTrinket}
+
+const (
+	// energy an attack costs without a weapon that says otherwise
+	defaultAttackEnergy = 15
+	defaultAttackRange  = 1
+)
+
+// attackStats sums up what the user's attacks do with what they have on.
+// A ranged weapon's range is how far it shoots, not how far it reaches.
+// The user's class scales the energy cost, and haste halves it
+func (wrld *world) attackStats(u user) (damage, reach, energyCost int) {
+	damage, reach, energyCost = u.damage, defaultAttackRange, defaultAttackEnergy
+	for _, slot := range slots {
+		it, ok := wrld.itemTypes[u.equipment[slot]]
+		if !ok {
+			continue
+		}
+		damage += it.Damage
+		if slot == slotWeapon {
+			if it.Range > 0 && !it.Ranged {
+				reach = it.Range
+			}
+			if it.EnergyCost > 0 {
+				energyCost = it.EnergyCost
+			}
+		}
+	}
+	if c, ok := wrld.classes[u.class]; ok {
+		energyCost = energyCost * c.AttackCost / 100
+	}
+	if u.hasEffect(effectHaste, time.Now()) {
+		energyCost /= 2
+	}
+	return damage, reach, energyCost
+}
+
+// defense sums the defense of everything the user has on
+func (wrld *world) defense(u user) int {
+	defense := 0
+	for _, slot := range slots {
+		defense += wrld.itemTypes[u.equipment[slot]].Defense
+	}
+	return defense
+}
+
+// soak reduces incoming damage by the victim's defense, point for point.
+// A hit reduced to nothing still lands one point 1 in defense+1 times, so
+// armor makes weak monsters less of a threat rather than none at all
+func (wrld *world) soak(victim user, amount int) int {
+	defense := wrld.defense(victim)
+	if amount <= 0 || defense == 0 {
+		return amount
+	}
+	if amount > defense {
+		return amount - defense
+	}
+	if rand.Intn(defense+1) == 0 {
+		return 1
+	}
+	return 0
+}
+
+// equip moves an item from the inventory into its slot, swapping out
+// whatever was there
+func (wrld *world) equip(userID string, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("usage: equip <item>")
+	}
+	it, ok := wrld.itemTypes[args[0]]
+	if !ok || it.Slot == "" {
+		return "", fmt.Errorf("%s can't be equipped", args[0])
+	}
+	tmpUser := wrld.users[userID]
+	if !tmpUser.takeItem(it.ID) {
+		return "", fmt.Errorf("no %s in inventory", it.ID)
+	}
+	if tmpUser.equipment == nil {
+		tmpUser.equipment = make(map[string]string)
+	}
+	if old, ok := tmpUser.equipment[it.Slot]; ok {
+		tmpUser.inventory = append(tmpUser.inventory, old)
+	}
+	tmpUser.equipment[it.Slot] = it.ID
+	wrld.users[userID] = tmpUser
+	return fmt.Sprintf("equipped %s", it.Name), nil
+}
+
+// unequip moves the item in a slot back into the inventory
+func (wrld *world) unequip(userID string, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("usage: unequip <weapon|armor|trinket>")
+	}
+	tmpUser := wrld.users[userID]
+	id, ok := tmpUser.equipment[args[0]]
+	if !ok {
+		return "", fmt.Errorf("nothing equipped as %s", args[0])
+	}
+	if len(tmpUser.inventory) >= inventoryCapacity {
+		return "", fmt.Errorf("inventory full (%d items)", inventoryCapacity)
+	}
+	delete(tmpUser.equipment, args[0])
+	tmpUser.inventory = append(tmpUser.inventory, id)
+	wrld.users[userID] = tmpUser
+	return fmt.Sprintf("unequipped %s", wrld.itemTypes[id].Name), nil
+}
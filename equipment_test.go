@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestEquip(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 1)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+
+	tmpUser := w.users["testingUser"]
+	tmpUser.inventory = []string{"sword", "spear", "potion"}
+	w.users["testingUser"] = tmpUser
+
+	if damage, reach, cost := w.attackStats(w.users["testingUser"]); damage != 1 || reach != 1 || cost != defaultAttackEnergy {
+		t.Errorf("unexpected bare handed stats %d %d %d", damage, reach, cost)
+	}
+
+	if _, err := w.equip("testingUser", []string{"potion"}); err == nil {
+		t.Error("expected potions to be unequippable")
+	}
+	if _, err := w.equip("testingUser", []string{"sword"}); err != nil {
+		t.Fatal(err)
+	}
+	if damage, _, cost := w.attackStats(w.users["testingUser"]); damage != 2 || cost != w.itemTypes["sword"].EnergyCost {
+		t.Errorf("unexpected sword stats %d %d", damage, cost)
+	}
+
+	// swapping weapons puts the old one back in the inventory
+	if _, err := w.equip("testingUser", []string{"spear"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, reach, _ := w.attackStats(w.users["testingUser"]); reach != 2 {
+		t.Errorf("expected the spear to reach 2 cells, got %d", reach)
+	}
+	if inv := strings.Join(w.users["testingUser"].inventory, ","); inv != "potion,sword" {
+		t.Errorf("unexpected inventory after swapping %q", inv)
+	}
+
+	if !strings.Contains(w.profileModal("testingUser"), "spear") {
+		t.Error("expected the weapon in the profile")
+	}
+
+	if _, err := w.unequip("testingUser", []string{"weapon"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.unequip("testingUser", []string{"weapon"}); err == nil {
+		t.Error("expected an empty slot to fail")
+	}
+}
+
+func TestArmorSoaksDamage(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+	w.createMonster("troll", position{x: 2, y: 4}, w.monsterTypes["troll"])
+	fightable(w, "testingUser")
+
+	tmpUser := w.users["testingUser"]
+	tmpUser.equipment[slotArmor] = "chainmail"
+	tmpUser.equipment[slotTrinket] = "amulet"
+	tmpUser.maxLife, tmpUser.life = 10, 10
+	w.users["testingUser"] = tmpUser
+
+	w.damageUser("troll", "testingUser", 5)
+	if got := w.users["testingUser"].life; got != 8 {
+		t.Errorf("expected 3 defense to soak 3 of 5 damage, life %d", got)
+	}
+}
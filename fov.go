@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+const (
+	defaultSightRadius = 12
+	maxSightRadius     = 20
+)
+
+// octant transforms used by the shadow caster, one column per octant
+var octants = [4][8]int{
+	{1, 0, 0, -1, -1, 0, 0, 1},
+	{0, 1, -1, 0, 0, -1, 1, 0},
+	{0, 1, 1, 0, 0, -1, -1, 0},
+	{1, 0, 0, 1, -1, 0, 0, -1},
+}
+
+// memory holds the cells a user has seen. It is shared between copies of
+// the user value, so it carries its own lock
+type memory struct {
+	sync.Mutex
+	cells map[string]bool
+}
+
+func newMemory() *memory {
+	return &memory{cells: make(map[string]bool)}
+}
+
+func (m *memory) remember(cells map[string]bool) {
+	m.Lock()
+	for cell := range cells {
+		m.cells[cell] = true
+	}
+	m.Unlock()
+}
+
+func (m *memory) has(cell string) bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.cells[cell]
+}
+
+// isOpaque reports whether a cell blocks sight. Off-map cells, walls and
+// opaque tiles do; cells that are only closed because someone stands there
+// do not
+func (loc *location) isOpaque(x, y int) bool {
+	pos, ok := loc.positions[fmt.Sprintf("%d,%d", x, y)]
+	if !ok {
+		return true
+	}
+	if pos.tile != nil {
+		return pos.tile.Opaque
+	}
+	return pos.closed && pos.userID == ""
+}
+
+// fieldOfView returns the set of cells visible from origin within radius
+// using recursive shadow casting
+func (loc *location) fieldOfView(origin position, radius int) map[string]bool {
+	visible := map[string]bool{origin.String(): true}
+	for oct := 0; oct < 8; oct++ {
+		loc.castLight(visible, origin.x, origin.y, radius, 1, 1.0, 0.0,
+			octants[0][oct], octants[1][oct], octants[2][oct], octants[3][oct])
+	}
+	return visible
+}
+
+func (loc *location) castLight(visible map[string]bool, ox, oy, radius, row int, start, end float64, xx, xy, yx, yy int) {
+	if start < end {
+		return
+	}
+	newStart := 0.0
+	for j := row; j <= radius; j++ {
+		dx, dy := -j-1, -j
+		blocked := false
+		for dx <= 0 {
+			dx++
+			x, y := ox+dx*xx+dy*xy, oy+dx*yx+dy*yy
+			leftSlope := (float64(dx) - 0.5) / (float64(dy) + 0.5)
+			rightSlope := (float64(dx) + 0.5) / (float64(dy) - 0.5)
+			if start < rightSlope {
+				continue
+			} else if end > leftSlope {
+				break
+			}
+
+			if dx*dx+dy*dy <= radius*radius {
+				visible[fmt.Sprintf("%d,%d", x, y)] = true
+			}
+
+			if blocked {
+				if loc.isOpaque(x, y) {
+					newStart = rightSlope
+					continue
+				}
+				blocked = false
+				start = newStart
+			} else if loc.isOpaque(x, y) && j < radius {
+				blocked = true
+				loc.castLight(visible, ox, oy, radius, j+1, start, leftSlope, xx, xy, yx, yy)
+				newStart = rightSlope
+			}
+		}
+		if blocked {
+			break
+		}
+	}
+}
+
+// setSight handles the `sight` console command
+func (u *user) setSight(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: sight <1-%d>", maxSightRadius)
+	}
+	radius, err := strconv.Atoi(args[0])
+	if err != nil || radius < 1 || radius > maxSightRadius {
+		return fmt.Errorf("usage: sight <1-%d>", maxSightRadius)
+	}
+	u.sightRadius = radius
+	return nil
+}
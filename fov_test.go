@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestFieldOfView(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 1)
+	loc := &w.locations[0]
+
+	visible := loc.fieldOfView(position{x: 2, y: 3}, defaultSightRadius)
+	for _, cell := range []string{"2,3", "3,4", "4,2", "5,3", "3,5"} {
+		if !visible[cell] {
+			t.Errorf("expected %s to be visible", cell)
+		}
+	}
+	for _, cell := range []string{"3,6", "7,2", "7,3"} {
+		if visible[cell] {
+			t.Errorf("expected %s to be hidden behind a wall", cell)
+		}
+	}
+
+	// the sight radius cuts off open cells
+	if visible := loc.fieldOfView(position{x: 2, y: 3}, 1); visible["2,5"] {
+		t.Error("expected 2,5 to be outside a sight radius of 1")
+	}
+}
+
+func TestRememberedTiles(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 1)
+	w.createUser("testingUser", 20, 10, position{x: 2, y: 3}, false)
+
+	tmpUser := w.users["testingUser"]
+	tmpUser.modal = loadModal("")
+	tmpUser.hud = hudOff
+	w.users["testingUser"] = tmpUser
+
+	w.display("testingUser", 20, 10)
+	if !tmpUser.seen.has("4,4") {
+		t.Fatal("expected 4,4 to be remembered after being seen")
+	}
+
+	// shrink sight so the wall falls out of view; it stays drawn instead of fog
+	tmpUser.sightRadius = 1
+	w.users["testingUser"] = tmpUser
+	rows := strings.Split(string(w.display("testingUser", 20, 10)), "\n")
+	// 5,2 sits at viewport column 13, row 4
+	if got := []rune(rows[3])[12]; got != '┃' {
+		t.Errorf("expected remembered wall at 5,2, got %q", got)
+	}
+}
+
+func TestSetSight(t *testing.T) {
+	u := user{sightRadius: defaultSightRadius}
+	if err := u.setSight([]string{"5"}); err != nil || u.sightRadius != 5 {
+		t.Errorf("unexpected sight radius %d (%v)", u.sightRadius, err)
+	}
+	if err := u.setSight([]string{"500"}); err == nil {
+		t.Error("expected sight radius over the limit to be rejected")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	hillGlyph = '░'
+	// how often the player holding the hill scores
+	hillTickEvery = time.Second
+)
+
+// kingOfTheHill scores a point every second for the player standing alone
+// on the hill. Nobody scores while it is contested
+type kingOfTheHill struct {
+	hill       rect
+	scoreLimit int
+	points     map[string]int
+	holder     string
+	next       time.Time
+}
+
+func newKingOfTheHill(loc *location, cfg modeConfig) (*kingOfTheHill, error) {
+	open := false
+	for _, pos := range loc.positions {
+		if !pos.closed && cfg.Hill.contains(*pos) {
+			open = true
+			break
+		}
+	}
+	if !open {
+		return nil, fmt.Errorf("koth: the hill has no open cells")
+	}
+	k := &kingOfTheHill{hill: cfg.Hill, scoreLimit: cfg.ScoreLimit, points: make(map[string]int)}
+	if k.scoreLimit <= 0 {
+		k.scoreLimit = 60
+	}
+	return k, nil
+}
+
+func (k *kingOfTheHill) Name() string { return "king of the hill" }
+
+func (k *kingOfTheHill) Goal() string {
+	return fmt.Sprintf("hold the hill alone for %d seconds", k.scoreLimit)
+}
+
+func (k *kingOfTheHill) PvP() bool { return true }
+
+func (k *kingOfTheHill) Start(wrld *world, now time.Time) {
+	k.points = make(map[string]int)
+	k.holder = ""
+	k.next = now.Add(hillTickEvery)
+}
+
+func (k *kingOfTheHill) Join(wrld *world, userID string) {}
+
+func (k *kingOfTheHill) Death(wrld *world, attackerID, victimID string, where position) {}
+
+func (k *kingOfTheHill) Tick(wrld *world, now time.Time) {
+	if now.Before(k.next) {
+		return
+	}
+	k.next = now.Add(hillTickEvery)
+
+	onHill := make([]string, 0)
+	for _, id := range wrld.players() {
+		if k.hill.contains(wrld.users[id].position) {
+			onHill = append(onHill, id)
+		}
+	}
+	holder := ""
+	if len(onHill) == 1 {
+		holder = onHill[0]
+		k.points[holder]++
+	}
+	if holder != k.holder {
+		switch {
+		case holder != "":
+			wrld.announce(fmt.Sprintf("%s holds the hill", holder))
+		case len(onHill) > 1:
+			wrld.announce("the hill is contested")
+		}
+		k.holder = holder
+	}
+}
+
+func (k *kingOfTheHill) Over(wrld *world, now time.Time) bool {
+	for _, p := range k.points {
+		if p >= k.scoreLimit {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *kingOfTheHill) Results(wrld *world) (string, []string) {
+	winner, standings := rank(k.points)
+	lines := make([]string, 0, len(standings))
+	for _, s := range standings {
+		lines = append(lines, fmt.Sprintf("%-20s %4ds", s.name, s.score))
+	}
+	return winner, lines
+}
+
+func (k *kingOfTheHill) Status(wrld *world, userID string) string {
+	holder := k.holder
+	if holder == "" {
+		holder = "nobody"
+	}
+	return fmt.Sprintf("hill: %s  you: %d/%d", holder, k.points[userID], k.scoreLimit)
+}
+
+// Glyph marks the open floor of the hill
+func (k *kingOfTheHill) Glyph(wrld *world, cell string) (rune, string, bool) {
+	pos, ok := wrld.locations[0].positions[cell]
+	if !ok || pos.closed || pos.tile != nil || len(pos.items) > 0 || !k.hill.contains(*pos) {
+		return 0, styleNone, false
+	}
+	return hillGlyph, styleHill, true
+}
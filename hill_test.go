@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKingOfTheHill(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("Alice", 80, 20, position{x: 3, y: 2}, false)
+	w.createUser("Bob", 80, 20, position{x: 10, y: 4}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	mode, err := w.newGameMode("koth", modeConfig{ScoreLimit: 2, Hill: rect{X1: 2, Y1: 2, X2: 4, Y2: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.setMode(mode, time.Minute)
+	now := time.Now()
+	w.tickMode(now)
+
+	w.tickMode(now.Add(hillTickEvery))
+	koth := mode.(*kingOfTheHill)
+	if koth.points["Alice"] != 1 || koth.holder != "Alice" {
+		t.Fatalf("expected Alice to hold the hill, points %v", koth.points)
+	}
+	if got := w.modeStatus("Bob", now); !strings.HasPrefix(got, "hill: Alice") {
+		t.Errorf("unexpected status %q", got)
+	}
+
+	w.teleport("Bob", position{x: 2, y: 2})
+	w.tickMode(now.Add(hillTickEvery * 2))
+	if koth.points["Alice"] != 1 || koth.holder != "" {
+		t.Errorf("expected nobody to score on a contested hill, points %v", koth.points)
+	}
+
+	w.teleport("Bob", position{x: 10, y: 4})
+	w.tickMode(now.Add(hillTickEvery * 3))
+	if w.round.running {
+		t.Fatal("expected the round to end at the score limit")
+	}
+	if !strings.Contains(w.round.results, "Winner: Alice") {
+		t.Errorf("expected Alice to win:\n%s", w.round.results)
+	}
+
+	if _, err := w.newGameMode("koth", modeConfig{Hill: rect{X1: 1, Y1: 1, X2: 1, Y2: 1}}); err == nil {
+		t.Error("expected a hill without open cells to be refused")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	hudOff    = "off"
+	hudTop    = "top"
+	hudBottom = "bottom"
+
+	hudEnergyBarWidth = 10
+)
+
+// hudRow returns the viewport row the HUD is drawn on, or 0 when hidden
+func (u *user) hudRow(height int) int {
+	switch u.hud {
+	case hudTop:
+		return 1
+	case hudBottom:
+		return height
+	}
+	return 0
+}
+
+// hudLine renders the status bar for the user, one entry per column,
+// padded or cut to width. status is the game mode's, if any
+func (u *user) hudLine(loc *location, width int, status string) []rune {
+	hearts := ""
+	for i := 0; i < u.life || i < u.maxLife; i++ {
+		if i < u.life {
+			hearts += "♥"
+		} else {
+			hearts += "♡"
+		}
+	}
+
+	filled := 0
+	if u.maxEnergy > 0 {
+		filled = u.energy * hudEnergyBarWidth / u.maxEnergy
+	}
+	if filled > hudEnergyBarWidth {
+		filled = hudEnergyBarWidth
+	}
+	bar := strings.Repeat("■", filled) + strings.Repeat("□", hudEnergyBarWidth-filled)
+
+	line := fmt.Sprintf(" %s [%s] %3d/%d  K:%d D:%d  (%s) %s",
+		hearts, bar, u.energy, u.maxEnergy, u.kills, u.deaths, u.position, loc.description)
+	if effects := u.effectSummary(time.Now(), true); effects != "" {
+		line += "  " + effects
+	}
+	switch {
+	case loc.inSafeZone(u.position):
+		line += "  safe"
+	case time.Now().Before(u.protectedUntil):
+		line += "  protected"
+	case u.pvp:
+		line += "  pvp"
+	}
+	if status != "" {
+		line += "  " + status
+	}
+
+	return fitCells(textCells(line), width)
+}
+
+// setHUD handles the `hud` console command: no argument toggles it,
+// otherwise on, off, top or bottom
+func (u *user) setHUD(args []string) error {
+	if len(args) == 0 {
+		if u.hud == hudOff {
+			u.hud = hudBottom
+		} else {
+			u.hud = hudOff
+		}
+		return nil
+	}
+	switch args[0] {
+	case "on":
+		u.hud = hudBottom
+	case hudOff, hudTop, hudBottom:
+		u.hud = args[0]
+	default:
+		return fmt.Errorf("usage: hud [on|off|top|bottom]")
+	}
+	return nil
+}
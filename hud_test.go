@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestHUDLine(t *testing.T) {
+	u := user{life: 2, maxLife: 3, energy: 75, maxEnergy: 150, kills: 4, deaths: 1, position: position{x: 2, y: 3}}
+	loc := &location{description: "init location"}
+
+	line := string(u.hudLine(loc, 80, ""))
+	for _, want := range []string{"♥♥♡", "[■■■■■□□□□□]", "K:4 D:1", "(2,3)", "init location"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("hud line %q missing %q", line, want)
+		}
+	}
+
+	if got := len(u.hudLine(loc, 10, "")); got != 10 {
+		t.Errorf("unexpected hud width. got %d, want %d", got, 10)
+	}
+}
+
+func TestHUDDisplay(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 1)
+	w.createUser("testingUser", 40, 10, position{x: 2, y: 3}, false)
+
+	rows := strings.Split(string(w.display("testingUser", 40, 10)), "\n")
+	if !strings.Contains(rows[9], "K:0 D:0") {
+		t.Errorf("expected hud on the bottom row, got %q", rows[9])
+	}
+
+	tmpUser := w.users["testingUser"]
+	if err := tmpUser.setHUD([]string{"top"}); err != nil {
+		t.Fatal(err)
+	}
+	w.users["testingUser"] = tmpUser
+	rows = strings.Split(string(w.display("testingUser", 40, 10)), "\n")
+	if !strings.Contains(rows[0], "K:0 D:0") || strings.Contains(rows[9], "K:0 D:0") {
+		t.Errorf("expected hud on the top row only, got %q / %q", rows[0], rows[9])
+	}
+
+	tmpUser.setHUD(nil)
+	w.users["testingUser"] = tmpUser
+	if strings.Contains(string(w.display("testingUser", 40, 10)), "K:0") {
+		t.Error("expected hud to be toggled off")
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	itemsPath         = "data/items.json"
+	inventoryCapacity = 10
+)
+
+// itemType is an item declared in data/items.json
+type itemType struct {
+	ID    string `json:"-"`
+	Glyph string `json:"glyph"`
+	Name  string `json:"name"`
+	// restored when the item is used; items restoring nothing can't be used
+	Life   int `json:"life"`
+	Energy int `json:"energy"`
+
+	// equipment, see equipment.go
+	Slot       string `json:"slot"`
+	Damage     int    `json:"damage"`
+	Range      int    `json:"range"`
+	EnergyCost int    `json:"energy_cost"`
+	Defense    int    `json:"defense"`
+	// ranged weapons fire projectiles Range cells instead of striking
+	Ranged bool `json:"ranged"`
+
+	// status effects, see effects.go: applied when used, or by a weapon
+	// to whoever it hits
+	Effect *effectSpec `json:"effect"`
+	OnHit  *effectSpec `json:"on_hit"`
+}
+
+func (it itemType) usable() bool {
+	return it.Life > 0 || it.Energy > 0 || it.Effect != nil
+}
+
+// lootEntry is a percent chance of a monster dropping an item
+type lootEntry struct {
+	Item   string `json:"item"`
+	Chance int    `json:"chance"`
+}
+
+func loadItemTypes(path string) map[string]itemType {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	items := make(map[string]itemType)
+	if err := json.Unmarshal(b, &items); err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	for id, it := range items {
+		it.ID = id
+		if it.Glyph == "" {
+			log.Fatalf("%s: item %q has no glyph", path, id)
+		}
+		if it.Name == "" {
+			it.Name = id
+		}
+		switch it.Slot {
+		case "", slotWeapon, slotArmor, slotTrinket:
+		default:
+			log.Fatalf("%s: item %q has unknown slot %q", path, id, it.Slot)
+		}
+		for _, spec := range []*effectSpec{it.Effect, it.OnHit} {
+			if spec == nil {
+				continue
+			}
+			if err := spec.validate(); err != nil {
+				log.Fatalf("%s: item %q: %v", path, id, err)
+			}
+		}
+		items[id] = it
+	}
+	return items
+}
+
+// dropLoot rolls the victim's loot table onto the cell they died on
+func (wrld *world) dropLoot(victim user, cell string) {
+	kind, ok := wrld.monsterTypes[victim.kind]
+	if !ok {
+		return
+	}
+	pos, ok := wrld.locations[0].positions[cell]
+	if !ok {
+		return
+	}
+	for _, loot := range kind.Loot {
+		if rand.Intn(100) < loot.Chance {
+			pos.items = append(pos.items, loot.Item)
+		}
+	}
+}
+
+// pickup moves the top item on the user's cell into their inventory, or
+// the gold there into their purse
+func (wrld *world) pickup(userID string) (string, error) {
+	if gold := wrld.collectGold(userID); gold > 0 {
+		return fmt.Sprintf("picked up %d gold", gold), nil
+	}
+	tmpUser := wrld.users[userID]
+	pos, ok := wrld.locations[0].positions[tmpUser.position.String()]
+	if !ok || len(pos.items) == 0 {
+		return "", fmt.Errorf("nothing here to pick up")
+	}
+	if len(tmpUser.inventory) >= inventoryCapacity {
+		return "", fmt.Errorf("inventory full (%d items)", inventoryCapacity)
+	}
+
+	id := pos.items[len(pos.items)-1]
+	pos.items = pos.items[:len(pos.items)-1]
+	tmpUser.inventory = append(tmpUser.inventory, id)
+	wrld.users[userID] = tmpUser
+	wrld.learnRecipes(userID)
+	return fmt.Sprintf("picked up %s", wrld.itemTypes[id].Name), nil
+}
+
+// drop leaves an item from the inventory on the user's cell
+func (wrld *world) drop(userID string, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("usage: drop <item>")
+	}
+	tmpUser := wrld.users[userID]
+	if !tmpUser.takeItem(args[0]) {
+		return "", fmt.Errorf("no %s in inventory", args[0])
+	}
+	if pos, ok := wrld.locations[0].positions[tmpUser.position.String()]; ok {
+		pos.items = append(pos.items, args[0])
+	}
+	wrld.users[userID] = tmpUser
+	return fmt.Sprintf("dropped %s", wrld.itemTypes[args[0]].Name), nil
+}
+
+// use consumes an item from the inventory
+func (wrld *world) use(userID string, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("usage: use <item>")
+	}
+	it, ok := wrld.itemTypes[args[0]]
+	if !ok || !it.usable() {
+		return "", fmt.Errorf("%s can't be used", args[0])
+	}
+	tmpUser := wrld.users[userID]
+	if !tmpUser.takeItem(it.ID) {
+		return "", fmt.Errorf("no %s in inventory", it.ID)
+	}
+
+	tmpUser.life += it.Life
+	if tmpUser.life > tmpUser.maxLife {
+		tmpUser.life = tmpUser.maxLife
+	}
+	tmpUser.energy += it.Energy
+	if tmpUser.energy > tmpUser.maxEnergy {
+		tmpUser.energy = tmpUser.maxEnergy
+	}
+	wrld.users[userID] = tmpUser
+	if it.Effect != nil {
+		wrld.addEffect(userID, *it.Effect, userID, time.Now())
+	}
+	return fmt.Sprintf("used %s", it.Name), nil
+}
+
+// takeItem removes one of the item from the inventory, reporting whether
+// there was one
+func (u *user) takeItem(id string) bool {
+	for i, held := range u.inventory {
+		if held == id {
+			u.inventory = append(u.inventory[:i:i], u.inventory[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// itemCounts groups an inventory by item, in a stable order
+func itemCounts(inventory []string) ([]string, map[string]int) {
+	counts := make(map[string]int)
+	ids := make([]string, 0)
+	for _, id := range inventory {
+		if counts[id] == 0 {
+			ids = append(ids, id)
+		}
+		counts[id]++
+	}
+	sort.Strings(ids)
+	return ids, counts
+}
+
+func (wrld *world) inventoryModal(userID string) string {
+	u := wrld.users[userID]
+	ids, counts := itemCounts(u.inventory)
+
+	rows := make([]string, 0, len(ids))
+	for _, id := range ids {
+		rows = append(rows, fmt.Sprintf("│ %-10.10s %-13.13s x%-2d │▒", id, wrld.itemTypes[id].Name, counts[id]))
+	}
+	if len(rows) == 0 {
+		rows = append(rows, "│ (empty)                      │▒")
+	}
+
+	return fmt.Sprintf(`
+┌──────────────────────────────┐
+│ Inventory             %2d/%-2d  │▒
+╞══════════════════════════════╡▒
+%s
+└──────────────────────────────┘▒
+ ▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒
+`, len(u.inventory), inventoryCapacity, strings.Join(rows, "\n"))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestLootAndInventory(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+	w.createMonster("dragon", position{x: 2, y: 4}, w.monsterTypes["dragon"])
+
+	// dragons always drop a scale and an elixir, and sometimes a ring
+	if !w.damageUser("testingUser", "dragon", 100) {
+		t.Fatal("expected the dragon to die")
+	}
+	pos := w.locations[0].positions["2,4"]
+	if got := pos.items; len(got) < 2 || got[0] != "scale" || got[1] != "elixir" {
+		t.Fatalf("expected loot on the dragon's cell, got %v", got)
+	}
+	pos.items = pos.items[:2]
+	pos.gold = 0
+
+	tmpUser := w.users["testingUser"]
+	tmpUser.position = position{x: 2, y: 4}
+	tmpUser.modal = loadModal("")
+	w.users["testingUser"] = tmpUser
+	if !strings.Contains(string(w.display("testingUser", 80, 20)), w.itemTypes["elixir"].Glyph) {
+		t.Error("expected the loot to be drawn")
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := w.pickup("testingUser"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := w.pickup("testingUser"); err == nil {
+		t.Error("expected nothing left to pick up")
+	}
+	if got := len(w.users["testingUser"].inventory); got != 2 {
+		t.Errorf("unexpected inventory size %d", got)
+	}
+
+	// used items restore up to the maximum
+	tmpUser = w.users["testingUser"]
+	tmpUser.life = 1
+	tmpUser.energy = tmpUser.maxEnergy - 1
+	w.users["testingUser"] = tmpUser
+	if _, err := w.use("testingUser", []string{"elixir"}); err != nil {
+		t.Fatal(err)
+	}
+	if u := w.users["testingUser"]; u.life != u.maxLife || u.energy != u.maxEnergy {
+		t.Errorf("expected full life and energy, got %d/%d and %d/%d", u.life, u.maxLife, u.energy, u.maxEnergy)
+	}
+	if _, err := w.use("testingUser", []string{"scale"}); err == nil {
+		t.Error("expected scales to be unusable")
+	}
+
+	if _, err := w.drop("testingUser", []string{"scale"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.users["testingUser"].inventory) != 0 || len(w.locations[0].positions["2,4"].items) != 1 {
+		t.Error("expected the scale to move from the inventory to the floor")
+	}
+}
+
+func TestInventoryCapacity(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 1)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+
+	pos := w.locations[0].positions["2,3"]
+	for i := 0; i <= inventoryCapacity; i++ {
+		pos.items = append(pos.items, "bone")
+	}
+	for i := 0; i < inventoryCapacity; i++ {
+		if _, err := w.pickup("testingUser"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := w.pickup("testingUser"); err == nil {
+		t.Error("expected a full inventory to refuse more items")
+	}
+	if !strings.Contains(w.inventoryModal("testingUser"), "bone") {
+		t.Error("expected bones listed in the inventory modal")
+	}
+}
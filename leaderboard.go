@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	boardSession = "session"
+	boardAllTime = "all"
+	// rows shown in the leaderboard modal
+	boardRows = 10
+)
+
+// what a leaderboard can be ranked by
+var boardSorts = map[string]func(a, b leaderEntry) bool{
+	"kills":    func(a, b leaderEntry) bool { return a.Kills > b.Kills },
+	"kd":       func(a, b leaderEntry) bool { return a.KD > b.KD },
+	"life":     func(a, b leaderEntry) bool { return a.LongestLife > b.LongestLife },
+	"monsters": func(a, b leaderEntry) bool { return a.MonstersSlain > b.MonstersSlain },
+}
+
+// leaderEntry is a player's line on a leaderboard
+type leaderEntry struct {
+	Rank   int     `json:"rank"`
+	Player string  `json:"player"`
+	Kills  int     `json:"kills"`
+	Deaths int     `json:"deaths"`
+	KD     float64 `json:"kd"`
+	// seconds
+	LongestLife   int `json:"longest_life"`
+	MonstersSlain int `json:"monsters_slain"`
+}
+
+func killRatio(kills, deaths int) float64 {
+	if deaths == 0 {
+		deaths = 1
+	}
+	return float64(kills) / float64(deaths)
+}
+
+// longestLife is the user's longest stretch without dying this session,
+// counting the current one
+func (u user) longestLife(now time.Time) time.Duration {
+	if current := now.Sub(u.lifeStart); current > u.bestLife {
+		return current
+	}
+	return u.bestLife
+}
+
+// recordDeath keeps the session stats and the profiles of those involved
+// up to date. It is called by damageUser once the victim has respawned
+func (wrld *world) recordDeath(attackerID, victimID string, now time.Time) {
+	if victim := wrld.users[victimID]; !victim.isNPC {
+		victim.bestLife = victim.longestLife(now)
+		victim.lifeStart = now
+		victim.streak = 0
+		wrld.users[victimID] = victim
+
+		p := wrld.profiles.get(victimID)
+		p.Deaths++
+		if life := int(victim.bestLife.Seconds()); life > p.LongestLife {
+			p.LongestLife = life
+		}
+	}
+
+	attacker, ok := wrld.users[attackerID]
+	if !ok || attacker.isNPC {
+		return
+	}
+	p := wrld.profiles.get(attackerID)
+	p.Kills++
+	attacker.streak++
+	if wrld.users[victimID].isNPC {
+		attacker.monstersSlain++
+		p.MonstersSlain++
+	}
+	wrld.users[attackerID] = attacker
+	wrld.emit(gameEvent{kind: eventKill, userID: attackerID, other: victimID})
+}
+
+// leaderboard ranks the players online (session) or everyone who has
+// played (all)
+func (wrld *world) leaderboard(scope, sortBy string, now time.Time) ([]leaderEntry, error) {
+	better, ok := boardSorts[sortBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort %q, use kills, kd, life or monsters", sortBy)
+	}
+
+	entries := make([]leaderEntry, 0)
+	switch scope {
+	case boardSession:
+		for _, id := range wrld.players() {
+			u := wrld.users[id]
+			entries = append(entries, leaderEntry{
+				Player:        id,
+				Kills:         u.kills,
+				Deaths:        u.deaths,
+				LongestLife:   int(u.longestLife(now).Seconds()),
+				MonstersSlain: u.monstersSlain,
+			})
+		}
+	case boardAllTime:
+		for id, p := range wrld.profiles.profiles {
+			e := leaderEntry{
+				Player:        id,
+				Kills:         p.Kills,
+				Deaths:        p.Deaths,
+				LongestLife:   p.LongestLife,
+				MonstersSlain: p.MonstersSlain,
+			}
+			// the life under way counts before it is saved
+			if u, ok := wrld.users[id]; ok && !u.isNPC {
+				if life := int(u.longestLife(now).Seconds()); life > e.LongestLife {
+					e.LongestLife = life
+				}
+			}
+			entries = append(entries, e)
+		}
+	default:
+		return nil, fmt.Errorf("unknown leaderboard %q, use session or all", scope)
+	}
+
+	for i := range entries {
+		entries[i].KD = killRatio(entries[i].Kills, entries[i].Deaths)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if better(entries[i], entries[j]) != better(entries[j], entries[i]) {
+			return better(entries[i], entries[j])
+		}
+		return entries[i].Player < entries[j].Player
+	})
+	for i := range entries {
+		entries[i].Rank = i + 1
+	}
+	return entries, nil
+}
+
+// topCommand handles `top`: the leaderboard modal, for the session or all
+// time, ranked by kills, kd, life or monsters
+func (wrld *world) topCommand(userID string, args []string, now time.Time) (string, error) {
+	scope, sortBy := boardSession, "kills"
+	for _, arg := range args {
+		if arg == boardSession || arg == boardAllTime {
+			scope = arg
+		} else {
+			sortBy = arg
+		}
+	}
+	entries, err := wrld.leaderboard(scope, sortBy, now)
+	if err != nil {
+		return "", fmt.Errorf("usage: top [session|all] [kills|kd|life|monsters]")
+	}
+
+	tmpUser := wrld.users[userID]
+	tmpUser.modal = loadModal(leaderboardModal(entries, scope, sortBy, userID))
+	tmpUser.activeModal = "top"
+	wrld.users[userID] = tmpUser
+	return "", nil
+}
+
+func formatLife(seconds int) string {
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+func leaderboardModal(entries []leaderEntry, scope, sortBy, userID string) string {
+	title := "this session"
+	if scope == boardAllTime {
+		title = "all time"
+	}
+	row := "│ %2s %-12.12s %5s %5s %6s %5s │▒\n"
+	width := 42
+
+	var b strings.Builder
+	b.WriteString("\n┌" + strings.Repeat("─", width) + "┐\n")
+	fmt.Fprintf(&b, "│ %-40.40s │▒\n", fmt.Sprintf("Leaderboard, %s, by %s", title, sortBy))
+	b.WriteString("╞" + strings.Repeat("═", width) + "╡▒\n")
+	fmt.Fprintf(&b, row, "#", "Player", "Kills", "K/D", "Life", "Mobs")
+	for i, e := range entries {
+		if i >= boardRows && e.Player != userID {
+			continue
+		}
+		fmt.Fprintf(&b, row, fmt.Sprint(e.Rank), e.Player, fmt.Sprint(e.Kills),
+			fmt.Sprintf("%.1f", e.KD), formatLife(e.LongestLife), fmt.Sprint(e.MonstersSlain))
+	}
+	if len(entries) == 0 {
+		fmt.Fprintf(&b, "│ %-40.40s │▒\n", "nobody yet")
+	}
+	b.WriteString("└" + strings.Repeat("─", width) + "┘▒\n")
+	b.WriteString(" " + strings.Repeat("▒", width+1) + "\n")
+	return b.String()
+}
+
+// getLeaderboard serves a leaderboard as JSON:
+// /leaderboard?scope=[session|all]&sort=[kills|kd|life|monsters]
+func getLeaderboard(wrld *world) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scope, sortBy := r.FormValue("scope"), r.FormValue("sort")
+		if scope == "" {
+			scope = boardSession
+		}
+		if sortBy == "" {
+			sortBy = "kills"
+		}
+
+		wrld.Lock()
+		entries, err := wrld.leaderboard(scope, sortBy, time.Now())
+		wrld.Unlock()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Scope   string        `json:"scope"`
+			Sort    string        `json:"sort"`
+			Entries []leaderEntry `json:"entries"`
+		}{scope, sortBy, entries})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLeaderboard(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 3)
+	w.createUser("Alice", 80, 20, position{x: 2, y: 3}, false)
+	w.createUser("Bob", 80, 20, position{x: 3, y: 3}, false)
+	w.createMonster("troll", position{x: 2, y: 4}, w.monsterTypes["troll"])
+	fightable(w, "Alice", "Bob")
+	w.Lock()
+	defer w.Unlock()
+
+	w.damageUser("Alice", "troll", 100)
+	w.damageUser("Bob", "Alice", 100)
+	w.damageUser("Alice", "Bob", 100)
+
+	now := time.Now()
+	entries, err := w.leaderboard(boardSession, "kills", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Player != "Alice" || entries[0].Kills != 2 || entries[0].MonstersSlain != 1 {
+		t.Fatalf("expected Alice on top with 2 kills and a monster, got %+v", entries)
+	}
+	if entries[1].KD != 1 || entries[0].KD != 2 {
+		t.Errorf("unexpected K/D %v and %v", entries[0].KD, entries[1].KD)
+	}
+
+	if _, err := w.topCommand("Bob", []string{"all", "monsters"}, now); err != nil {
+		t.Fatal(err)
+	}
+	modal := modalText(w.users["Bob"].modal)
+	if !strings.Contains(modal, "all time, by monsters") || !strings.Contains(modal, "Alice") {
+		t.Errorf("expected the all-time board in the modal:\n%s", modal)
+	}
+	if _, err := w.topCommand("Bob", []string{"bogus"}, now); err == nil {
+		t.Error("expected an unknown sort to be refused")
+	}
+
+	// a life lasts until death
+	tmpUser := w.users["Bob"]
+	tmpUser.lifeStart = now.Add(-time.Minute)
+	w.users["Bob"] = tmpUser
+	if got := w.users["Bob"].longestLife(now); got != time.Minute {
+		t.Errorf("expected the current life to count, got %s", got)
+	}
+}
+
+func TestLeaderboardEndpoint(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("Alice", 80, 20, position{x: 2, y: 3}, false)
+	w.profiles.get("Zed").Kills = 7
+
+	rec := httptest.NewRecorder()
+	getLeaderboard(w)(rec, httptest.NewRequest("GET", "/leaderboard?scope=all&sort=kills", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body)
+	}
+	var board struct {
+		Scope   string        `json:"scope"`
+		Entries []leaderEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &board); err != nil {
+		t.Fatal(err)
+	}
+	if board.Scope != "all" || len(board.Entries) == 0 || board.Entries[0].Player != "Zed" {
+		t.Errorf("expected Zed to lead all time, got %+v", board)
+	}
+
+	rec = httptest.NewRecorder()
+	getLeaderboard(w)(rec, httptest.NewRequest("GET", "/leaderboard?scope=yesterday", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a bad scope to be refused, got %d", rec.Code)
+	}
+}
+
+// modalText puts a modal back together line by line
+func modalText(modal map[string]rune) string {
+	var b strings.Builder
+	for y := 1; ; y++ {
+		if _, ok := modal[position{x: 1, y: y}.String()]; !ok && y > 2 {
+			break
+		}
+		for x := 1; ; x++ {
+			r, ok := modal[position{x: x, y: y}.String()]
+			if !ok {
+				break
+			}
+			b.WriteRune(r)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
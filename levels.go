@@ -0,0 +1,95 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	baseLifeRegen = time.Second * 5
+	minLifeRegen  = time.Second
+
+	// xp for killing a player, multiplied by their level
+	playerKillXP = 10
+
+	// stat growth per level
+	lifePerLevel   = 1
+	energyPerLevel = 15
+	// each level shortens the time between life regen ticks by a tenth
+	lifeRegenFactor = 0.9
+	// energy regen grows by one every this many levels
+	levelsPerEnergyRegen = 3
+)
+
+// xpForLevel is the total xp needed to reach level
+func xpForLevel(level int) int {
+	return 50 * (level - 1) * (level - 1)
+}
+
+// killXP is what killing victim is worth
+func (wrld *world) killXP(victim user) int {
+	if victim.isNPC {
+		if kind, ok := wrld.monsterTypes[victim.kind]; ok {
+			return kind.XP * victim.level
+		}
+	}
+	return playerKillXP * victim.level
+}
+
+// awardKillXP gives the xp for a kill to the killer, split evenly with the
+// rest of their party. The killer keeps what doesn't divide
+func (wrld *world) awardKillXP(killerID string, victim user) {
+	killer, ok := wrld.users[killerID]
+	if !ok || killer.isNPC {
+		return
+	}
+	xp := wrld.killXP(victim)
+	sharers := []string{killerID}
+	if killer.party != "" {
+		sharers = sharers[:0]
+		for _, id := range wrld.partyMembers(killer.party) {
+			if id != victim.userID {
+				sharers = append(sharers, id)
+			}
+		}
+	}
+	share := xp / len(sharers)
+	for _, id := range sharers {
+		u := wrld.users[id]
+		gained := share
+		if id == killerID {
+			gained += xp % len(sharers)
+		}
+		if levels := u.gainXP(gained); levels > 0 {
+			log.Printf("user %s reached level %d", id, u.level)
+		}
+		wrld.users[id] = u
+	}
+}
+
+// gainXP adds xp and applies any level ups, returning how many there were
+func (u *user) gainXP(xp int) int {
+	u.xp += xp
+	levels := 0
+	for u.xp >= xpForLevel(u.level+1) {
+		u.levelUp()
+		levels++
+	}
+	return levels
+}
+
+func (u *user) levelUp() {
+	u.level++
+	u.maxLife += lifePerLevel
+	u.life += lifePerLevel
+	u.maxEnergy += energyPerLevel
+
+	u.lifeRegen = time.Duration(float64(u.lifeRegen) * lifeRegenFactor)
+	if u.lifeRegen < minLifeRegen {
+		u.lifeRegen = minLifeRegen
+	}
+	if u.level%levelsPerEnergyRegen == 0 {
+		u.energyRegen++
+	}
+	u.syncRegen()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestGainXP(t *testing.T) {
+	u := user{level: 1, maxLife: 3, life: 3, maxEnergy: 150, lifeRegen: baseLifeRegen, energyRegen: 1}
+
+	if levels := u.gainXP(xpForLevel(2) - 1); levels != 0 || u.level != 1 {
+		t.Fatalf("leveled up too early: level %d", u.level)
+	}
+	if levels := u.gainXP(xpForLevel(4)); levels != 3 || u.level != 4 {
+		t.Fatalf("expected three level ups to 4, got %d to %d", levels, u.level)
+	}
+	if u.maxLife != 3+3*lifePerLevel || u.maxEnergy != 150+3*energyPerLevel {
+		t.Errorf("unexpected stats after leveling: life %d energy %d", u.maxLife, u.maxEnergy)
+	}
+	if u.lifeRegen >= baseLifeRegen || u.energyRegen != 2 {
+		t.Errorf("unexpected regen after leveling: life %s energy %d", u.lifeRegen, u.energyRegen)
+	}
+}
+
+func TestKillXP(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 3)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+	w.createUser("otherUser", 80, 20, position{x: 3, y: 3}, false)
+	w.createMonster("troll", position{x: 2, y: 4}, w.monsterTypes["troll"])
+	fightable(w, "testingUser", "otherUser")
+
+	if !w.damageUser("testingUser", "troll", 100) {
+		t.Fatal("expected the troll to die")
+	}
+	if got, want := w.users["testingUser"].xp, w.monsterTypes["troll"].XP; got != want {
+		t.Errorf("unexpected xp for a troll. got %d, want %d", got, want)
+	}
+
+	tmpUser := w.users["otherUser"]
+	tmpUser.level = 3
+	w.users["otherUser"] = tmpUser
+	before := w.users["testingUser"].xp
+	w.damageUser("testingUser", "otherUser", 100)
+	if got := w.users["testingUser"].xp - before; got != 3*playerKillXP {
+		t.Errorf("expected xp scaled by victim level. got %d, want %d", got, 3*playerKillXP)
+	}
+	if profile := w.profileModal("testingUser"); !strings.Contains(profile, fmt.Sprintf("Level:  %3d", w.users["testingUser"].level)) {
+		t.Errorf("level missing from profile: %s", profile)
+	}
+}
+
+func TestRespawnLife(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+	w.createMonster("goblin", position{x: 2, y: 4}, w.monsterTypes["goblin"])
+
+	for _, maxLife := range []int{2, 8} {
+		// respawning protects the player again
+		fightable(w, "testingUser")
+		tmpUser := w.users["testingUser"]
+		tmpUser.maxLife = maxLife
+		w.users["testingUser"] = tmpUser
+		if !w.damageUser("goblin", "testingUser", 100) {
+			t.Fatal("expected the player to die")
+		}
+		if got := w.users["testingUser"].life; got != maxLife {
+			t.Errorf("expected to respawn with %d life, got %d", maxLife, got)
+		}
+	}
+}
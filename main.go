@@ -30,14 +30,85 @@ type user struct {
 	commChan    chan string // not yet in use
 	modal       map[string]rune
 	activeModal string
+	// when the last achievement toast is due to close, see achievements.go
+	toastUntil  time.Time
 	lastCommand time.Time
 
 	isNPC     bool
 	energy    int
+	maxEnergy int
 	life      int
+	maxLife   int
 	deaths    int
 	kills     int
+	level     int
+	xp        int
 	character rune
+	hud       string
+	color     bool
+
+	sightRadius int
+	seen        *memory
+
+	// monster chase state, see monsterIntent
+	target   string
+	lastSeen position
+	lostFor  int
+	// the path being followed toward pathGoal, see pathStep
+	path     []position
+	pathGoal position
+
+	kind     string
+	zone     string
+	damage   int
+	speed    time.Duration
+	behavior string
+
+	lifeRegen   time.Duration
+	energyRegen int
+
+	inventory []string
+	equipment map[string]string
+
+	class string
+
+	// see parties.go; the invite is who it is from
+	party       string
+	partyInvite string
+	notices     []notice
+
+	// see pvp.go
+	pvp            bool
+	pvpChanged     time.Time
+	protectedUntil time.Time
+
+	// capture the flag team, see ctf.go
+	team string
+	// knocked out of a round without respawns, watching until it ends, see
+	// royale.go
+	out bool
+
+	// session stats, see leaderboard.go
+	monstersSlain int
+	lifeStart     time.Time
+	bestLife      time.Duration
+	// kills since the last death, see achievements.go
+	streak int
+
+	// status effects, see effects.go
+	effects []*effect
+
+	// townsfolk, who don't fight, have a role and a name, see npcs.go
+	role string
+	name string
+	// what a shopkeeper trades in, see economy.go
+	shop string
+
+	gold int
+	// who last asked to trade with the user, see trade.go
+	tradeRequest string
+	// progress on the quests under way, see quests.go
+	quests map[string]int
 }
 
 func (p position) String() string {
@@ -64,12 +135,49 @@ type world struct {
 	users       map[string]user
 	connections int
 	startTime   time.Time
+
+	monsterTypes map[string]monsterType
+	itemTypes    map[string]itemType
+	classes      map[string]classType
+	questTypes   map[string]questType
+	shopTypes    map[string]shopType
+	recipeTypes  map[string]recipeType
+	projectiles  []*projectile
+
+	nextTerrainTick time.Time
+
+	// whether party members and monsters can hurt their own side
+	friendlyFire bool
+	// parties formed so far, for naming the next one
+	parties int
+
+	// nil for the endless free-for-all, see modes.go
+	mode  GameMode
+	round round
+
+	profiles *profileStore
+
+	// the fights of the bosses alive, see bosses.go
+	bosses map[string]*bossState
+	// open trades, under both parties, see trade.go
+	trades map[string]*trade
 }
 
 type location struct {
 	description string
 	display     []byte
 	positions   map[string]*position
+	wallStyle   string
+	spawns      []position
+	spawnTable  []spawnEntry
+	zones       []*zone
+	legend      map[rune]*tileType
+	safeZones   []rect
+	lairs       []*lair
+	// ids of the townsfolk
+	npcs []string
+	// walkable cells reachable from the spawns, see achievements.go
+	reachable []string
 
 	sync.Mutex
 }
@@ -80,11 +188,30 @@ type position struct {
 	description string
 	character   rune
 	userID      string
+	flash       bool
+	items       []string
+	// glyph of a projectile passing through, 0 when there is none
+	projectile rune
+	// applied to whoever steps onto the cell
+	effect *effectSpec
+	// terrain from the map legend, nil for plain floor and walls
+	tile *tileType
+	// whether a hidden tile has been stepped on
+	revealed bool
+	// a boss's slam is about to land here, see bosses.go
+	warning bool
+	// dropped by monsters, see economy.go
+	gold int
 }
 
 func main() {
 	log.Println("Starting")
-	defer profile.Start(profile.CPUProfile).Stop()
+	// profiles.json is saved on the way out too, so shutting down is left to
+	// saveOnShutdown rather than the profiler's own signal hook
+	cfg := *profile.CPUProfile
+	cfg.NoShutdownHook = true
+	prof := profile.Start(&cfg)
+	defer prof.Stop()
 
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
@@ -93,14 +220,18 @@ func main() {
 	listener := make(chan command)
 
 	w := genWorld("maps/map_2.map", 10, 500)
+	w.profiles = loadProfiles(profilesPath)
+	go w.saveOnShutdown(prof)
 
 	go gameRunner(w, listener)
 
 	http.HandleFunc("/", getWorld(w))
 	http.HandleFunc("/cmd", receiveCommand(listener))
+	http.HandleFunc("/leaderboard", getLeaderboard(w))
 
 	log.Println("Registered /")
 	log.Println("Registered /cmd?uid=[string]&key=[char]")
+	log.Println("Registered /leaderboard?scope=[session|all]&sort=[kills|kd|life|monsters]")
 
 	log.Println("Listening on :8888")
 
@@ -111,11 +242,31 @@ func main() {
 }
 
 func genWorld(mapPath string, monsterSaturationPercent, capacity int) *world {
+	meta := loadMapMeta(mapPath)
 	loc := make([]location, 1)
 	loc[0] = location{
-		description: "init location",
+		description: meta.Name,
 		display:     []byte("some map"),
 		positions:   loadMap(mapPath),
+		wallStyle:   meta.WallStyle,
+	}
+	for _, spawn := range meta.Spawns {
+		loc[0].spawns = append(loc[0].spawns, spawn.position())
+	}
+	loc[0].safeZones = meta.SafeZones
+	if err := loc[0].applyLegend(meta.Legend); err != nil {
+		log.Fatalf("%s: %v", metaPath(mapPath), err)
+	}
+	for _, def := range meta.Effects {
+		if err := def.Effect.validate(); err != nil {
+			log.Fatalf("%s: %v", metaPath(mapPath), err)
+		}
+		pos, ok := loc[0].positions[fmt.Sprintf("%d,%d", def.X, def.Y)]
+		if !ok || pos.closed {
+			log.Fatalf("%s: effect at (%d,%d) is not on an open cell", metaPath(mapPath), def.X, def.Y)
+		}
+		spec := def.Effect
+		pos.effect = &spec
 	}
 	commands := make([]command, 0)
 	w := &world{locations: loc,
@@ -123,30 +274,98 @@ func genWorld(mapPath string, monsterSaturationPercent, capacity int) *world {
 		commands:  commands,
 		users:     make(map[string]user),
 		startTime: time.Now(),
-	}
 
-	// spawn monsters
-	opens := make([]*position, 0)
-	openCount := 0
-	// todo - don't count user spawn area as open
-	for _, pos := range w.locations[0].positions {
-		if pos.closed == false {
-			openCount++
-			opens = append(opens, pos)
+		monsterTypes: loadMonsterTypes(monstersPath),
+		itemTypes:    loadItemTypes(itemsPath),
+		classes:      loadClasses(classesPath),
+		questTypes:   loadQuestTypes(questsPath),
+		shopTypes:    loadShopTypes(shopsPath),
+		recipeTypes:  loadRecipeTypes(recipesPath),
+		friendlyFire: meta.FriendlyFire,
+		profiles:     newProfileStore(""),
+		bosses:       make(map[string]*bossState),
+		trades:       make(map[string]*trade),
+	}
+	if meta.Mode != "" {
+		cfg, ok := meta.Modes[meta.Mode]
+		if !ok {
+			log.Fatalf("%s: mode %q is not configured under modes", metaPath(mapPath), meta.Mode)
 		}
+		mode, err := w.newGameMode(meta.Mode, cfg)
+		if err != nil {
+			log.Fatalf("%s: %v", metaPath(mapPath), err)
+		}
+		w.setMode(mode, time.Second*time.Duration(cfg.RoundSeconds))
 	}
-	rand.Seed(time.Now().Unix())
-	created := 0
-	for monsterCount := monsterSaturationPercent * openCount / 100; monsterCount > 0; monsterCount-- {
-		idx := rand.Intn(len(opens))
-		pos := opens[idx]
 
-		randInt := rand.Intn(2000000000)
+	for _, kind := range w.monsterTypes {
+		for _, loot := range kind.Loot {
+			if _, ok := w.itemTypes[loot.Item]; !ok {
+				log.Fatalf("%s: %s drops unknown item %q", monstersPath, kind.Name, loot.Item)
+			}
+		}
+		if kind.Boss == nil {
+			continue
+		}
+		for _, phase := range kind.Boss.Phases {
+			if phase.Summon == nil {
+				continue
+			}
+			if add, ok := w.monsterTypes[phase.Summon.Type]; !ok || add.Boss != nil {
+				log.Fatalf("%s: %s summons %q, which is not a monster", monstersPath, kind.Name, phase.Summon.Type)
+			}
+		}
+	}
 
-		if w.createUser(strconv.Itoa(randInt), 80, 20, *pos, true) {
-			created++
+	w.locations[0].spawnTable = meta.Monsters
+	if len(meta.Monsters) == 0 {
+		w.locations[0].spawnTable = defaultSpawnTable(w.monsterTypes)
+	}
+	for _, entry := range w.locations[0].spawnTable {
+		kind, ok := w.monsterTypes[entry.Type]
+		if !ok {
+			log.Fatalf("%s: unknown monster type %q", metaPath(mapPath), entry.Type)
+		}
+		if kind.Boss != nil {
+			log.Fatalf("%s: %q is a boss and only appears in lairs", metaPath(mapPath), entry.Type)
+		}
+	}
+	lairs, err := w.locations[0].newLairs(meta.Lairs, w.monsterTypes)
+	if err != nil {
+		log.Fatalf("%s: %v", metaPath(mapPath), err)
+	}
+	w.locations[0].lairs = lairs
+	if err := validateQuests(w.questTypes, w.monsterTypes, w.itemTypes); err != nil {
+		log.Fatalf("%s: %v", questsPath, err)
+	}
+	if err := validateRecipes(w.recipeTypes, w.itemTypes); err != nil {
+		log.Fatalf("%s: %v", recipesPath, err)
+	}
+	for _, shop := range w.shopTypes {
+		for _, prices := range []map[string]int{shop.Sells, shop.Buys} {
+			for item := range prices {
+				if _, ok := w.itemTypes[item]; !ok {
+					log.Fatalf("%s: %s trades in unknown item %q", shopsPath, shop.ID, item)
+				}
+			}
+		}
+	}
+	for _, def := range meta.NPCs {
+		if err := w.createNPC(def); err != nil {
+			log.Fatalf("%s: %v", metaPath(mapPath), err)
 		}
 	}
+	if err := w.validateGivers(); err != nil {
+		log.Fatalf("%s: %v", questsPath, err)
+	}
+
+	// spawn monsters
+	w.locations[0].zones = w.locations[0].newZones(meta.Zones, monsterSaturationPercent)
+	rand.Seed(time.Now().Unix())
+	// the monsters already spawned start roaming straight away
+	w.Lock()
+	created := w.fillZones()
+	w.Unlock()
 	log.Printf("spawned %d monsters", created)
 	return w
 }
@@ -156,12 +375,49 @@ func getWorld(wrld *world) http.HandlerFunc {
 		// todo sanitize
 		width, _ := strconv.Atoi(r.FormValue("w"))
 		height, _ := strconv.Atoi(r.FormValue("h"))
-		// todo - have spawn points
-		if wrld.createUser(r.FormValue("uid"), width, height, position{x: 2, y: 3}, false) {
-			w.Write(wrld.display(r.FormValue("uid"), width, height))
-		} else {
+		// a negative size would slice past the start of the rows
+		if width < 0 {
+			width = 0
+		}
+		if height < 0 {
+			height = 0
+		}
+		// joining changes the world the game loop is playing out
+		wrld.Lock()
+		_, joined := wrld.users[r.FormValue("uid")]
+		// the class is picked on joining, later polls repeat it. A bad one
+		// is turned away before the player exists without it
+		class := r.FormValue("class")
+		if class != "" && !joined {
+			if _, err := wrld.findClass(class); err != nil {
+				wrld.Unlock()
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error() + "\n"))
+				return
+			}
+		}
+		if !wrld.createUser(r.FormValue("uid"), width, height, wrld.locations[0].spawns[0], false) {
+			wrld.Unlock()
 			w.Write([]byte("unable to join, world is at capacity\n"))
+			return
+		}
+		if mode := r.FormValue("color"); mode != "" {
+			if color, err := parseColorMode(mode); err == nil {
+				tmpUser := wrld.users[r.FormValue("uid")]
+				tmpUser.color = color
+				wrld.users[r.FormValue("uid")] = tmpUser
+			}
+		}
+		if class != "" && !joined {
+			if _, err := wrld.chooseClass(r.FormValue("uid"), []string{class}); err != nil {
+				wrld.Unlock()
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error() + "\n"))
+				return
+			}
 		}
+		wrld.Unlock()
+		w.Write(wrld.display(r.FormValue("uid"), width, height))
 	}
 }
 
@@ -215,10 +471,13 @@ func gameRunner(wrld *world, listener chan command) {
 	}()
 }
 
+// createUser adds a user, handing players to the running round. It is
+// called with the world locked, as the game mode hooks expect
 func (wrld *world) createUser(userID string, viewPortWidth, viewPortHeight int, startingPosition position, isNPC bool) bool {
 	if _, found := wrld.users[userID]; found {
 		return true
-	} else if len(wrld.users) >= wrld.capacity {
+	} else if len(wrld.users)-len(wrld.locations[0].npcs) >= wrld.capacity {
+		// townsfolk don't take up room
 		return false
 	}
 
@@ -227,12 +486,18 @@ func (wrld *world) createUser(userID string, viewPortWidth, viewPortHeight int,
 	maxLife := 3
 	maxEnergey := 150
 	rand.Seed(int64(time.Now().Nanosecond()))
+	// wide glyphs here are swapped for narrow ones when drawn, see width.go
 	characters := []rune{'◊', 'ᐉ', 'ᛤ', '៙', '⁖', '⁘', '⁙', '⊙', '⍾', '⎔', '⎊', '⎈', '◈', '☆', '☃', '☢', '☣', '♀', '♂', '⚉', '♜', '⛄'}
 	randChar := characters[rand.Intn(len(characters))]
 
 	comm := make(chan string)
 	kill := make(chan bool)
 
+	sightRadius := defaultSightRadius
+	if isNPC {
+		sightRadius = monsterSightRadius
+	}
+
 	wrld.users[userID] = user{
 		position:    startingPosition,
 		viewPortX:   viewPortWidth,
@@ -240,12 +505,47 @@ func (wrld *world) createUser(userID string, viewPortWidth, viewPortHeight int,
 		commChan:    comm,
 		killChan:    kill,
 		energy:      maxEnergey / 10,
+		maxEnergy:   maxEnergey,
 		life:        maxLife,
+		maxLife:     maxLife,
+		hud:         hudBottom,
+		sightRadius: sightRadius,
+		seen:        newMemory(),
 		character:   randChar,
 		isNPC:       isNPC,
 		lastCommand: time.Now(),
+		lifeStart:   time.Now(),
 		modal:       loadModal(help()),
 		userID:      userID,
+		damage:      1,
+		level:       1,
+		lifeRegen:   baseLifeRegen,
+		energyRegen: 1,
+		equipment:   make(map[string]string),
+	}
+	if !isNPC {
+		tmpUser := wrld.users[userID]
+		tmpUser.protectedUntil = time.Now().Add(spawnProtection)
+		wrld.users[userID] = tmpUser
+	}
+	{
+		// life and energy regen are permanent effects ticked by the game loop
+		tmpUser := wrld.users[userID]
+		tmpUser.effects = tmpUser.baseEffects()
+		wrld.users[userID] = tmpUser
+	}
+	if !isNPC {
+		wrld.modeJoin(userID)
+	}
+
+	if isNPC {
+		tmpUser := wrld.users[userID]
+		tmpUser.speed = time.Millisecond * time.Duration(rand.Intn(1000)+400)
+		tmpUser.behavior = behaviorHunter
+		if kind, ok := wrld.pickMonsterType(wrld.locations[0].spawnTable); ok {
+			tmpUser.applyMonsterType(kind)
+		}
+		wrld.users[userID] = tmpUser
 	}
 
 	// todo - thought: instead of passing in the world, pass in a channel tied to this user
@@ -266,7 +566,6 @@ func (wrld *world) createUser(userID string, viewPortWidth, viewPortHeight int,
 				delete(w.users, userID)
 
 				tmpPos := w.locations[0].positions[pos]
-				tmpPos.character = ' '
 				tmpPos.closed = false
 				tmpPos.userID = ""
 				w.locations[0].positions[pos] = tmpPos
@@ -275,121 +574,57 @@ func (wrld *world) createUser(userID string, viewPortWidth, viewPortHeight int,
 		}
 	}(wrld, userID)
 
-	go func(w *world, userID string) {
-		c := time.Tick(time.Second * 5)
-		for _ = range c {
-			tmpUser := w.users[userID]
-			select {
-			case _, ok := <-w.users[userID].killChan:
-				if !ok {
-					return
-				}
-			default:
-				if tmpUser.life < maxLife {
-					tmpUser.life++
-				}
-				w.users[userID] = tmpUser
-			}
-		}
-	}(wrld, userID)
+	return true
+}
 
-	go func(w *world, userID string) {
-		c := time.Tick(time.Millisecond * 500)
-		for _ = range c {
-			tmpUser := w.users[userID]
-			select {
-			case _, ok := <-w.users[userID].killChan:
-				if !ok {
-					return
-				}
-			default:
-				if tmpUser.energy < maxEnergey {
-					tmpUser.energy++
-				}
-				w.users[userID] = tmpUser
+// startMonster sets the monster going: every turn it decides what to do and
+// sends that as a command like any other client. Kept apart from createUser
+// so tests can place monsters that stay put
+func (wrld *world) startMonster(mID string) {
+	go func(w *world, mID string) {
+		for {
+			// read every turn, the speed may change while alive
+			w.Lock()
+			speed := w.users[mID].speed
+			if w.users[mID].hasEffect(effectHaste, time.Now()) {
+				speed /= 2
 			}
-		}
-	}(wrld, userID)
+			w.Unlock()
+			time.Sleep(speed)
 
-	if isNPC {
-		go func(w *world, mID string) {
-			rDur := (time.Duration)(rand.Intn(1000) + 400)
-			c := time.Tick(time.Millisecond * rDur)
-			for _ = range c {
-
-				// check if user is close by and attack
-				// but make monsters unable to attack monsters
-
-				x, y := wrld.users[mID].position.x, wrld.users[mID].position.y
-				for i := x - 1; i <= x+1; i++ {
-					for j := y - 1; j <= y+1; j++ {
-						if !(i == x && j == y) {
-							wrld.Lock()
-							cell := fmt.Sprintf("%d,%d", i, j)
-							opponentID := ""
-							if _, ok := wrld.locations[0].positions[cell]; ok {
-								opponentID = wrld.locations[0].positions[cell].userID
-							}
-							isNPC := wrld.users[opponentID].isNPC
-							wrld.Unlock()
-							if opponentID != "" && !isNPC {
-								resp, err := http.Get(fmt.Sprintf("http://localhost:8888/cmd?uid=%s&key=>attack", mID))
-								wrld.connectionInc()
-								if err != nil {
-									log.Println(err)
-									if strings.Contains(err.Error(), "no such host") || strings.Contains(err.Error(), "can't assign requested address") {
-										time.Sleep(time.Second * 10)
-									}
-								} else {
-									wrld.connectionDec()
-									io.Copy(ioutil.Discard, resp.Body) // read this might help reduce open sockets
-									resp.Body.Close()
-								}
-								continue
-							}
-						}
-					}
-				}
+			// chase, attack or flee from players in sight, otherwise wander
+			w.Lock()
+			intent := w.monsterIntent(mID)
+			w.Unlock()
 
-				// move in random direction or (todo) towards user
-				direction := ""
-				switch rand.Intn(4) {
-				case 0:
-					direction = "mw"
-				case 1:
-					direction = "ma"
-				case 2:
-					direction = "ms"
-				case 3:
-					direction = "md"
-				}
-				resp, err := http.Get(fmt.Sprintf("http://localhost:8888/cmd?uid=%s&key=%s", mID, direction))
+			if intent != "" {
+				resp, err := http.Get(fmt.Sprintf("http://localhost:8888/cmd?uid=%s&key=%s", mID, intent))
 				wrld.connectionInc()
 				if err != nil {
 					log.Println(err)
-					if strings.Contains(err.Error(), "no such host") {
+					if strings.Contains(err.Error(), "no such host") || strings.Contains(err.Error(), "can't assign requested address") {
 						time.Sleep(time.Second * 10)
 					}
 				} else {
 					wrld.connectionDec()
-					io.Copy(ioutil.Discard, resp.Body)
+					io.Copy(ioutil.Discard, resp.Body) // read this might help reduce open sockets
 					resp.Body.Close()
 				}
-
-				if w.users[mID].deaths > 0 {
-					tmpPos := w.locations[0].positions[w.users[mID].position.String()]
-					tmpPos.character = ' '
-					tmpPos.userID = ""
-					tmpPos.closed = false
-					w.locations[0].positions[w.users[mID].position.String()] = tmpPos
-					delete(w.users, mID)
-					return
-				}
 			}
-		}(wrld, userID)
-	}
 
-	return true
+			w.Lock()
+			if w.users[mID].deaths > 0 {
+				tmpPos := w.locations[0].positions[w.users[mID].position.String()]
+				tmpPos.userID = ""
+				tmpPos.closed = false
+				w.locations[0].positions[w.users[mID].position.String()] = tmpPos
+				delete(w.users, mID)
+				w.Unlock()
+				return
+			}
+			w.Unlock()
+		}
+	}(wrld, mID)
 }
 
 func (wrld *world) connectionInc() {
@@ -409,6 +644,15 @@ func (wrld *world) updateBoard() {
 	wrld.Lock()
 	defer wrld.Unlock()
 
+	now := time.Now()
+	wrld.populate(now)
+	wrld.tickBosses(now)
+	wrld.tickEffects(now)
+	wrld.tickTerrain(now)
+	wrld.moveProjectiles()
+	wrld.tickMode(now)
+	wrld.saveProfiles(now)
+
 	if len(wrld.commands) == 0 {
 		return
 	}
@@ -449,101 +693,163 @@ func (wrld *world) updateBoard() {
 					tmpUser.viewPortY = height
 					wrld.users[cmd.userID] = tmpUser
 				}
-			case "profile":
-				go func(w *world, userID string) {
-					// todo: can you set up a doWhile package?
-					// pass in a func (?) bool (shrug)
-					// do ...
-					tmpUser := w.users[userID]
-					tmpUser.modal = loadModal(tmpUser.profileModal())
-					tmpUser.activeModal = "profile"
-					wrld.users[userID] = tmpUser
-					// while ...
-					c := time.Tick(time.Millisecond * 500)
-					for _ = range c {
-						tmpUser := w.users[userID]
-						if tmpUser.activeModal != "profile" {
-							return
-						}
-						tmpUser.modal = loadModal(tmpUser.profileModal())
-						wrld.users[userID] = tmpUser
-					}
-				}(wrld, cmd.userID)
-			case "info":
-				go func(w *world, userID string) {
-					// todo: can you set up a doWhile package?
-					// pass in a func (?) bool (shrug)
-					// do ...
-					tmpUser := w.users[userID]
-					tmpUser.modal = loadModal(w.info())
-					tmpUser.activeModal = "info"
-					wrld.users[userID] = tmpUser
-					// while ...
-					c := time.Tick(time.Millisecond * 500)
-					for _ = range c {
-						tmpUser := w.users[userID]
-						if tmpUser.activeModal != "info" {
-							return
-						}
-						tmpUser.modal = loadModal(w.info())
-						wrld.users[userID] = tmpUser
+			case "hud":
+				tmpUser := wrld.users[cmd.userID]
+				if err := tmpUser.setHUD(cmdPart[1:]); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+				wrld.users[cmd.userID] = tmpUser
+			case "color":
+				tmpUser := wrld.users[cmd.userID]
+				if len(cmdPart) == 2 {
+					if color, err := parseColorMode(cmdPart[1]); err != nil {
+						statusCode = http.StatusBadRequest
+						message = err.Error()
+					} else {
+						tmpUser.color = color
 					}
-				}(wrld, cmd.userID)
-			case "attack":
-				// get all units in range and deal damage
-				// if their life falls to >0, recreate them
-				attackEnergy := 15
-				if wrld.users[cmd.userID].energy < attackEnergy {
-					message = "Not enough energy"
-					cmd.result <- commandStatus{statusCode: statusCode, message: message}
-
-					continue
+				} else {
+					tmpUser.color = !tmpUser.color
 				}
-
+				wrld.users[cmd.userID] = tmpUser
+			case "sight":
 				tmpUser := wrld.users[cmd.userID]
-				tmpUser.energy -= attackEnergy
+				if err := tmpUser.setSight(cmdPart[1:]); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
 				wrld.users[cmd.userID] = tmpUser
-
-				x, y := wrld.users[cmd.userID].position.x, wrld.users[cmd.userID].position.y
-				log.Printf("user %s at (%d,%d) attack", cmd.userID, x, y)
-				for i := x - 1; i <= x+1; i++ {
-					for j := y - 1; j <= y+1; j++ {
-						if !(i == x && j == y) {
-							// don't damage current user
-							curPos := fmt.Sprintf("%d,%d", i, j)
-							if pos, ok := wrld.locations[0].positions[curPos]; ok {
-								go areaAttack(pos)
-								if pos.userID != "" {
-									tmp_user := wrld.users[pos.userID]
-									tmp_user.life--
-									wrld.users[pos.userID] = tmp_user
-									if wrld.users[pos.userID].life <= 0 {
-										// plase damaged user at start
-										// todo: if isNPC - place is non existant location?
-										{
-											tmpUser := wrld.users[pos.userID]
-											tmpUser.position.x = 2
-											tmpUser.position.y = 3
-											tmpUser.deaths++
-											tmpUser.life = 5
-											wrld.users[pos.userID] = tmpUser
-										}
-										{
-											tmpUser := wrld.users[cmd.userID]
-											tmpUser.kills++
-											wrld.users[cmd.userID] = tmpUser
-										}
-										// clear out the previous cell
-										tmp_pos := wrld.locations[0].positions[curPos]
-										tmp_pos.character = ' '
-										tmp_pos.closed = false
-										tmp_pos.userID = ""
-										wrld.locations[0].positions[curPos] = tmp_pos
-									}
-								}
-							}
-						}
-					}
+			case "profile":
+				go wrld.refreshModal(cmd.userID, "profile", wrld.profileModal)
+			case "info":
+				go wrld.refreshModal(cmd.userID, "info", func(string) string { return wrld.info() })
+			case "map":
+				go wrld.refreshModal(cmd.userID, "map", wrld.minimapModal)
+			case "inventory":
+				go wrld.refreshModal(cmd.userID, "inventory", wrld.inventoryModal)
+			case "pickup":
+				var err error
+				if message, err = wrld.pickup(cmd.userID); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+			case "drop":
+				var err error
+				if message, err = wrld.drop(cmd.userID, cmdPart[1:]); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+			case "use":
+				var err error
+				if message, err = wrld.use(cmd.userID, cmdPart[1:]); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+			case "equip":
+				var err error
+				if message, err = wrld.equip(cmd.userID, cmdPart[1:]); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+			case "class":
+				var err error
+				if message, err = wrld.chooseClass(cmd.userID, cmdPart[1:]); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+			case "ability":
+				var err error
+				if message, err = wrld.useAbility(cmd.userID, cmdPart[1:]); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+			case "open":
+				var err error
+				if message, err = wrld.openDoor(cmd.userID); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+			case "party":
+				var err error
+				// as typed, so chat keeps its case
+				args := strings.Split(strings.TrimSpace(cmd.cmd[1:]), " ")[1:]
+				if message, err = wrld.partyCommand(cmd.userID, args); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+			case "top":
+				var err error
+				if message, err = wrld.topCommand(cmd.userID, cmdPart[1:], now); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+			case "round":
+				var err error
+				if message, err = wrld.roundCommand(cmd.userID, now); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+			case "talk":
+				var err error
+				if message, err = wrld.talk(cmd.userID, cmdPart[1:]); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+			case "quests":
+				var err error
+				if message, err = wrld.questsCommand(cmd.userID); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+			case "buy":
+				var err error
+				if message, err = wrld.buy(cmd.userID, cmdPart[1:]); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+			case "sell":
+				var err error
+				if message, err = wrld.sell(cmd.userID, cmdPart[1:]); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+			case "craft":
+				var err error
+				if message, err = wrld.craft(cmd.userID, cmdPart[1:]); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+			case "recipes":
+				var err error
+				if message, err = wrld.recipesCommand(cmd.userID); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+			case "trade":
+				var err error
+				if message, err = wrld.tradeCommand(cmd.userID, cmdPart[1:]); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+			case "pvp":
+				var err error
+				if message, err = wrld.setPvP(cmd.userID, cmdPart[1:], now); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+			case "unequip":
+				var err error
+				if message, err = wrld.unequip(cmd.userID, cmdPart[1:]); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
+				}
+			case "attack":
+				// hit everything in range, or aim with a direction
+				var err error
+				if message, err = wrld.attack(cmd.userID, cmdPart[1:]); err != nil {
+					statusCode = http.StatusBadRequest
+					message = err.Error()
 				}
 			default:
 				statusCode = http.StatusNotImplemented
@@ -558,6 +864,11 @@ func (wrld *world) updateBoard() {
 
 		curPos := wrld.users[cmd.userID].position
 		newPos := applyMove(curPos, cmd.cmd)
+		if wrld.bossSpec(wrld.users[cmd.userID]) != nil {
+			// bosses cover more than the one cell
+			wrld.moveBoss(cmd.userID, newPos, now)
+			continue
+		}
 
 		if _, ok := wrld.locations[0].positions[newPos.String()]; !ok {
 			if curPos.String() == "0,0" {
@@ -572,11 +883,16 @@ func (wrld *world) updateBoard() {
 		// https://github.com/golang/go/issues/3117
 		// cannot yet assign to a field of a map indirectly
 		tmpUser := wrld.users[cmd.userID]
-		if tmpUser.energy <= 0 {
+		cost := wrld.locations[0].positions[newPos.String()].moveCost()
+		if tmpUser.energy < cost || tmpUser.hasEffect(effectStun, now) || tmpUser.out {
+			continue
+		}
+		if tmpUser.isNPC && wrld.locations[0].inSafeZone(newPos) {
+			// monsters keep out of safe zones
 			continue
 		}
 		tmpUser.position = newPos
-		tmpUser.energy--
+		tmpUser.energy -= cost
 		wrld.users[cmd.userID] = tmpUser
 
 		// update former/current position first. new pos may overwrite it,
@@ -591,6 +907,17 @@ func (wrld *world) updateBoard() {
 		tmpPosB.userID = cmd.userID
 		wrld.locations[0].positions[newPos.String()] = tmpPosB
 
+		if tmpPosB.tile != nil && tmpPosB.tile.Hidden {
+			tmpPosB.revealed = true
+		}
+		if tmpPosB.effect != nil {
+			wrld.addEffect(cmd.userID, *tmpPosB.effect, "", now)
+		}
+		if gold := wrld.collectGold(cmd.userID); gold > 0 {
+			wrld.notify(cmd.userID, fmt.Sprintf("picked up %d gold", gold))
+		}
+		wrld.emit(gameEvent{kind: eventExplore, userID: cmd.userID})
+
 	}
 
 	// clear the played through commands
@@ -598,18 +925,18 @@ func (wrld *world) updateBoard() {
 }
 
 func areaAttack(pos *position) {
-	//original := pos.character
+	// the terrain underneath is left alone; only the occupant flashes
 	if pos.userID == "" {
 		return
 	}
-	pos.character = '*'
+	pos.flash = true
 	<-time.Tick(time.Second * 1)
 
-	pos.character = ' '
+	pos.flash = false
 }
 
 func (wrld *world) display(uid string, width, height int) []byte {
-	body := make([]rune, 0)
+	out := &screen{color: wrld.users[uid].color}
 
 	userX := wrld.users[uid].position.x
 	userY := wrld.users[uid].position.y
@@ -617,11 +944,26 @@ func (wrld *world) display(uid string, width, height int) []byte {
 	// offsetY := 0
 	// offsetX := 0
 
-	visibilityX := 15
-	visibilityY := 10
+	tmpUser := wrld.users[uid]
+	visible := wrld.locations[0].fieldOfView(tmpUser.position, tmpUser.sightRadius)
+	if tmpUser.seen != nil {
+		tmpUser.seen.remember(visible)
+	}
+	hudRow := tmpUser.hudRow(height)
+	var hud []rune
+	if hudRow > 0 {
+		hud = tmpUser.hudLine(&wrld.locations[0], width, wrld.modeStatus(uid, time.Now()))
+	}
+	notices := tmpUser.noticeRows(time.Now(), width)
 
 	for y := 1; y <= height; y++ {
+		covered := false
 		for x := 1; x <= width; x++ {
+			if covered {
+				// the right half of a wide rune drawn in the previous column
+				covered = false
+				continue
+			}
 			// WAT
 			// do something better here for the translation
 			translationX := -1*(wrld.users[uid].viewPortX/2) + userX + x
@@ -629,27 +971,99 @@ func (wrld *world) display(uid string, width, height int) []byte {
 			cell := fmt.Sprintf("%d,%d", translationX, translationY)
 			pos := wrld.locations[0].positions[cell]
 			theRune := ' '
-
-			if r, ok := wrld.users[uid].modal[fmt.Sprintf("%d,%d", x, y)]; ok {
+			style := styleNone
+			overlay := false
+
+			if y == hudRow {
+				theRune = hud[x-1]
+				style = styleHUD
+				overlay = true
+			} else if r, ok := wrld.users[uid].modal[fmt.Sprintf("%d,%d", x, y)]; ok {
 				theRune = r
+				overlay = true
+			} else if row := notices[y]; x <= len(row) {
+				theRune = row[x-1]
+				style = styleNotice
+				overlay = true
 			} else if pos == nil {
 				theRune = '·'
-			} else if abs(translationX-userX) > visibilityX || abs(translationY-userY) > visibilityY {
-				theRune = '·'
+				style = styleFog
+			} else if !visible[cell] {
+				if tmpUser.seen != nil && tmpUser.seen.has(cell) {
+					// remembered tiles keep their terrain but not who stands there
+					theRune = pos.glyph()
+					style = styleRemembered
+				} else {
+					theRune = '·'
+					style = styleFog
+				}
 			} else if pos.userID != "" {
-				// todo: depending on user class, use different symbols and colors
-				theRune = wrld.users[pos.userID].character
+				occupant := wrld.users[pos.userID]
+				theRune = occupant.character
+				switch {
+				case pos.flash:
+					style = styleFlash
+				case pos.warning:
+					style = styleWarning
+				case pos.userID == uid:
+					style = styleSelf
+				case occupant.role != "":
+					style = styleTownsfolk
+				case occupant.isNPC:
+					style = styleMonster
+					if kind, ok := wrld.monsterTypes[occupant.kind]; ok && kind.Color != "" {
+						style = kind.Color
+					}
+				case wrld.allies(uid, pos.userID):
+					style = styleAlly + playerStyle(pos.userID)
+					if !out.color {
+						theRune = allyGlyph
+					}
+				default:
+					style = playerStyle(pos.userID)
+				}
+			} else if pos.projectile != 0 {
+				theRune = pos.projectile
+				style = styleProjectile
+			} else if pos.warning {
+				theRune = '!'
+				style = styleWarning
+			} else if r, s, ok := wrld.modeGlyph(cell); ok {
+				theRune = r
+				style = s
+			} else if len(pos.items) > 0 {
+				theRune = []rune(wrld.itemTypes[pos.items[len(pos.items)-1]].Glyph)[0]
+				style = styleItem
+			} else if pos.gold > 0 {
+				theRune = '$'
+				style = styleGold
 			} else {
-				theRune = pos.character
+				theRune = pos.glyph()
+				if pos.tile != nil {
+					if !pos.tile.Hidden || pos.revealed {
+						style = pos.tile.Style
+					}
+				} else if pos.closed {
+					style = wrld.locations[0].wallStyle
+				}
 			}
 
-			body = append(body, theRune)
+			if !overlay {
+				theRune = narrowGlyph(theRune)
+			} else if runeWidth(theRune) == 2 && x < width {
+				covered = true
+			} else if runeWidth(theRune) != 1 {
+				// orphaned or clipped halves of wide runes, and zero width runes
+				theRune = ' '
+			}
+
+			out.put(theRune, style)
 
 		}
-		body = append(body, '\n')
+		out.newline()
 	}
 
-	return []byte(string(body))
+	return out.bytes()
 }
 
 func abs(i int) int {
@@ -717,7 +1131,7 @@ func loadModal(s string) map[string]rune {
 	for _, line := range lines {
 		y++
 		x = 0
-		for _, r := range line {
+		for _, r := range textCells(line) {
 			x++
 			m[fmt.Sprintf("%d,%d", x, y)] = r
 		}
@@ -752,27 +1166,73 @@ func help() string {
 ╞══════════════════════════════════╡▒
 │ Movement: w,a,s,d                │▒
 │ Attack: x                        │▒
+│ Aim: attack w, a, s or d         │▒
 │                                  │▒
 │ Console commands must be started │▒
 │ with ":".                        │▒
 │                                  │▒
 │ - help   - clear    - resize     │▒
 │ - attack - . (redo) - profile    │▒
-│ - info                           │▒
+│ - info   - hud      - color      │▒
+│ - sight  - map      - inventory  │▒
+│ - pickup - drop     - use        │▒
+│ - equip  - unequip  - class      │▒
+│ - open   - party    - ability    │▒
+│ - pvp    - round    - top        │▒
+│ - talk   - quests   - trade      │▒
+│ - buy    - sell     - craft      │▒
+│ - recipes                        │▒
 └──────────────────────────────────┘▒
  ▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒
 `
 }
 
-func (u *user) profileModal() string {
+// refreshModal puts a live modal up for the user and rebuilds it every half
+// second until they close it. It holds the world lock while it does, so it
+// never writes back a stale copy of the user over a trade or a purchase
+func (wrld *world) refreshModal(userID, name string, build func(userID string) string) {
+	for first := true; ; first = false {
+		wrld.Lock()
+		tmpUser, ok := wrld.users[userID]
+		if !ok || (!first && tmpUser.activeModal != name) {
+			wrld.Unlock()
+			return
+		}
+		tmpUser.modal = loadModal(build(userID))
+		tmpUser.activeModal = name
+		wrld.users[userID] = tmpUser
+		wrld.Unlock()
+		time.Sleep(time.Millisecond * 500)
+	}
+}
+
+func (wrld *world) profileModal(userID string) string {
+	u := wrld.users[userID]
+	damage, reach, attackEnergy := wrld.attackStats(u)
 	return fmt.Sprintf(`
 ┌─────────────────────────────┐
 │ User Info    %12s %3c │▒
 ╞═════════════════════════════╡▒
 │ Life:   %3d     Deaths: %3d │▒
 │ Energy: %3d     Kills:  %3d │▒
+│ Level:  %3d     XP: %7d │▒
+│ Class:   %-18.18s │▒
+│ Gold:    %-18d │▒
+│                             │▒
+│ Weapon:  %-18.18s │▒
+│ Armor:   %-18.18s │▒
+│ Trinket: %-18.18s │▒
+│ Dmg: %2d  Reach: %d  Cost: %2d │▒
+│ Defense: %2d                 │▒
+│ Effects: %-18.18s │▒
+│ PvP:     %-18.18s │▒
 │                             │▒
-└─────────────────────────────┘▒
+%s└─────────────────────────────┘▒
  ▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒
-`, u.userID, u.character, u.life, u.deaths, u.energy, u.kills)
+`, u.userID, u.character, u.life, u.deaths, u.energy, u.kills, u.level, u.xp, u.class, u.gold,
+		wrld.itemTypes[u.equipment[slotWeapon]].Name,
+		wrld.itemTypes[u.equipment[slotArmor]].Name,
+		wrld.itemTypes[u.equipment[slotTrinket]].Name,
+		damage, reach, attackEnergy, wrld.defense(u), u.effectSummary(time.Now(), false),
+		map[bool]string{true: "on", false: "off"}[u.pvp], wrld.achievementRows(userID))
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+// mapMeta is the optional sidecar to a map file. For maps/map_2.map it is
+// read from maps/map_2.json
+type mapMeta struct {
+	Name   string      `json:"name"`
+	Spawns []metaPoint `json:"spawns"`
+	// SGR parameters the walls are drawn with in color mode
+	WallStyle string `json:"wall_style"`
+	// which monster types appear, see data/monsters.json
+	Monsters []spawnEntry `json:"monsters"`
+	// regions with their own monster population, see spawner.go
+	Zones []metaZone `json:"zones"`
+	// cells that apply a status effect to whoever steps on them
+	Effects []metaEffect `json:"effects"`
+	// terrain for characters of the map file, see terrain.go
+	Legend map[string]tileType `json:"legend"`
+	// whether party members and monsters can hurt their own side
+	FriendlyFire bool `json:"friendly_fire"`
+	// regions where no damage can be dealt, see pvp.go
+	SafeZones []rect `json:"safe_zones"`
+	// the game mode played in rounds, empty for a free-for-all, and the
+	// settings of each mode, see modes.go
+	Mode  string                `json:"mode"`
+	Modes map[string]modeConfig `json:"modes"`
+	// where bosses spawn, see bosses.go
+	Lairs []metaLair `json:"lairs"`
+	// townsfolk, see npcs.go
+	NPCs []metaNPC `json:"npcs"`
+}
+
+type metaPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+func (p metaPoint) position() position {
+	return position{x: p.X, y: p.Y}
+}
+
+type metaEffect struct {
+	X      int        `json:"x"`
+	Y      int        `json:"y"`
+	Effect effectSpec `json:"effect"`
+}
+
+func metaPath(mapPath string) string {
+	return strings.TrimSuffix(mapPath, ".map") + ".json"
+}
+
+// loadMapMeta reads the sidecar for mapPath, falling back to defaults
+// when there is none
+func loadMapMeta(mapPath string) mapMeta {
+	meta := mapMeta{Name: "init location", WallStyle: styleWall}
+
+	b, err := ioutil.ReadFile(metaPath(mapPath))
+	if os.IsNotExist(err) {
+		meta.Spawns = []metaPoint{{X: 2, Y: 3}}
+		return meta
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &meta); err != nil {
+		log.Fatalf("%s: %v", metaPath(mapPath), err)
+	}
+	if len(meta.Spawns) == 0 {
+		meta.Spawns = []metaPoint{{X: 2, Y: 3}}
+	}
+	return meta
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	minimapPlayer = '◉'
+	minimapSpawn  = '⌂'
+	minimapFog    = '·'
+
+	brailleBase = 0x2800
+)
+
+// braille dot bits indexed by [row][column] within a 2x4 character cell
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// bounds returns the extent of the location's cells
+func (loc *location) bounds() (minX, minY, maxX, maxY int) {
+	first := true
+	for _, pos := range loc.positions {
+		if first || pos.x < minX {
+			minX = pos.x
+		}
+		if first || pos.y < minY {
+			minY = pos.y
+		}
+		if first || pos.x > maxX {
+			maxX = pos.x
+		}
+		if first || pos.y > maxY {
+			maxY = pos.y
+		}
+		first = false
+	}
+	return
+}
+
+// minimapModal draws the whole location in braille, scaled down until it
+// fits the user's viewport. Only remembered cells are drawn
+func (wrld *world) minimapModal(userID string) string {
+	u := wrld.users[userID]
+	loc := &wrld.locations[0]
+	minX, minY, maxX, maxY := loc.bounds()
+	mapW, mapH := maxX-minX+1, maxY-minY+1
+
+	// room for the frame, its shadow and the title rows
+	availW, availH := u.viewPortX-3, u.viewPortY-6
+	if availW < 1 {
+		availW = 1
+	}
+	if availH < 1 {
+		availH = 1
+	}
+	scale := 1
+	for (mapW+2*scale-1)/(2*scale) > availW || (mapH+4*scale-1)/(4*scale) > availH {
+		scale++
+	}
+	cols, rows := (mapW+2*scale-1)/(2*scale), (mapH+4*scale-1)/(4*scale)
+
+	// char cell a map cell falls into
+	charAt := func(x, y int) (int, int) {
+		return (x - minX) / (2 * scale), (y - minY) / (4 * scale)
+	}
+
+	grid := make([][]rune, rows)
+	explored := make([][]bool, rows)
+	for r := range grid {
+		grid[r] = make([]rune, cols)
+		explored[r] = make([]bool, cols)
+		for c := range grid[r] {
+			grid[r][c] = brailleBase
+		}
+	}
+	for cell, pos := range loc.positions {
+		if u.seen == nil || !u.seen.has(cell) {
+			continue
+		}
+		c, r := charAt(pos.x, pos.y)
+		explored[r][c] = true
+		if pos.closed && pos.userID == "" {
+			dotX, dotY := (pos.x-minX)/scale%2, (pos.y-minY)/scale%4
+			grid[r][c] |= brailleDots[dotY][dotX]
+		}
+	}
+	for r := range grid {
+		for c := range grid[r] {
+			if !explored[r][c] {
+				grid[r][c] = minimapFog
+			}
+		}
+	}
+	for _, spawn := range loc.spawns {
+		if c, r := charAt(spawn.x, spawn.y); u.seen != nil && u.seen.has(spawn.String()) {
+			grid[r][c] = minimapSpawn
+		}
+	}
+	if c, r := charAt(u.position.x, u.position.y); r >= 0 && r < rows && c >= 0 && c < cols {
+		grid[r][c] = minimapPlayer
+	}
+
+	title := fmt.Sprintf(" Map: %s ", loc.description)
+	inner := cols
+	if n := stringWidth(title); n > inner {
+		inner = n
+	}
+
+	var b strings.Builder
+	b.WriteString("\n┌" + strings.Repeat("─", inner) + "┐\n")
+	b.WriteString("│" + title + strings.Repeat(" ", inner-stringWidth(title)) + "│▒\n")
+	b.WriteString("╞" + strings.Repeat("═", inner) + "╡▒\n")
+	for _, row := range grid {
+		b.WriteString("│" + string(row) + strings.Repeat(" ", inner-cols) + "│▒\n")
+	}
+	b.WriteString("└" + strings.Repeat("─", inner) + "┘▒\n")
+	b.WriteString(" " + strings.Repeat("▒", inner+2) + "\n")
+	return b.String()
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestMinimapModal(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_2.map", 0, 1)
+	w.createUser("testingUser", 80, 24, w.locations[0].spawns[0], false)
+
+	// nothing explored yet: only the player marker and fog
+	modal := w.minimapModal("testingUser")
+	if !strings.ContainsRune(modal, minimapPlayer) {
+		t.Errorf("expected the player marker in %q", modal)
+	}
+	if strings.ContainsRune(modal, minimapSpawn) {
+		t.Error("spawn point should stay hidden until explored")
+	}
+
+	w.display("testingUser", 80, 24)
+	modal = w.minimapModal("testingUser")
+	if strings.IndexFunc(modal, func(r rune) bool { return r > brailleBase && r <= brailleBase+0xff }) < 0 {
+		t.Errorf("expected explored walls drawn in braille: %q", modal)
+	}
+
+	lines := strings.Split(strings.Trim(modal, "\n"), "\n")
+	if len(lines) > 24 {
+		t.Errorf("minimap taller than the viewport: %d rows", len(lines))
+	}
+	for _, line := range lines {
+		if n := len([]rune(line)); n > 80 {
+			t.Errorf("minimap row wider than the viewport: %d", n)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRoundLength = time.Minute * 5
+	// pause between the end of one round and the start of the next
+	roundBreak = time.Second * 15
+	// standings shown in the results modal
+	maxResultLines = 8
+)
+
+// GameMode is a set of rules played in rounds on top of the free-for-all.
+// Which one runs, if any, is picked by a map sidecar. Every hook is called
+// with the world locked
+type GameMode interface {
+	Name() string
+	// Goal is announced when a round starts
+	Goal() string
+	Start(wrld *world, now time.Time)
+	Tick(wrld *world, now time.Time)
+	// Join is called when a player enters the world during a round, and for
+	// everyone already in it when a round starts
+	Join(wrld *world, userID string)
+	// Death is called once the victim has respawned; where is the cell they
+	// died on
+	Death(wrld *world, attackerID, victimID string, where position)
+	// Over reports whether the round was decided before time ran out
+	Over(wrld *world, now time.Time) bool
+	// Results names the winner, or "nobody", and lists the standings
+	Results(wrld *world) (string, []string)
+	// Status is a short summary of the round for the user's HUD
+	Status(wrld *world, userID string) string
+	// Glyph draws the mode's own entities, such as flags
+	Glyph(wrld *world, cell string) (rune, string, bool)
+	// PvP reports whether players fight each other regardless of their pvp
+	// flag
+	PvP() bool
+}
+
+// lobby is implemented by modes that need enough players before a round
+// can start
+type lobby interface {
+	// Ready reports whether a round can start, and otherwise what it is
+	// waiting for
+	Ready(wrld *world) (bool, string)
+}
+
+// round tracks the rounds of the world's game mode
+type round struct {
+	number  int
+	running bool
+	length  time.Duration
+	ends    time.Time
+	// when the next round starts
+	next time.Time
+	// the last round's results modal
+	results string
+}
+
+// modeConfig is how a game mode is set up in a map sidecar, under the
+// mode's name in "modes"
+type modeConfig struct {
+	RoundSeconds int `json:"round_seconds"`
+	ScoreLimit   int `json:"score_limit"`
+
+	// capture the flag, see ctf.go
+	Teams []metaTeam `json:"teams"`
+	// king of the hill, see hill.go
+	Hill rect `json:"hill"`
+	// survival, see survival.go
+	Waves       int          `json:"waves"`
+	FirstWave   int          `json:"first_wave"`
+	WaveGrowth  int          `json:"wave_growth"`
+	WaveSeconds int          `json:"wave_seconds"`
+	DeathLimit  int          `json:"death_limit"`
+	Monsters    []spawnEntry `json:"monsters"`
+	// battle royale, see royale.go
+	MinPlayers    int `json:"min_players"`
+	ShrinkSeconds int `json:"shrink_seconds"`
+	FinalSize     int `json:"final_size"`
+	StormDamage   int `json:"storm_damage"`
+}
+
+// newGameMode builds the named mode from its sidecar config
+func (wrld *world) newGameMode(name string, cfg modeConfig) (GameMode, error) {
+	loc := &wrld.locations[0]
+	switch name {
+	case "ctf":
+		return newCaptureTheFlag(loc, cfg)
+	case "koth":
+		return newKingOfTheHill(loc, cfg)
+	case "survival":
+		for _, entry := range cfg.Monsters {
+			if _, ok := wrld.monsterTypes[entry.Type]; !ok {
+				return nil, fmt.Errorf("survival: unknown monster type %q", entry.Type)
+			}
+		}
+		if len(cfg.Monsters) == 0 {
+			cfg.Monsters = loc.spawnTable
+		}
+		return newSurvival(cfg), nil
+	case "royale":
+		return newBattleRoyale(cfg), nil
+	}
+	return nil, fmt.Errorf("unknown game mode %q", name)
+}
+
+// setMode puts the world under a game mode, the first round starting on
+// the next tick
+func (wrld *world) setMode(mode GameMode, length time.Duration) {
+	if length <= 0 {
+		length = defaultRoundLength
+	}
+	wrld.mode = mode
+	wrld.round = round{length: length}
+}
+
+// tickMode runs the game mode and starts and ends its rounds. It is called
+// from the game loop with the world locked
+func (wrld *world) tickMode(now time.Time) {
+	if wrld.mode == nil {
+		return
+	}
+	if !wrld.round.running {
+		if now.Before(wrld.round.next) {
+			return
+		}
+		if l, ok := wrld.mode.(lobby); ok {
+			if ready, _ := l.Ready(wrld); !ready {
+				return
+			}
+		}
+		wrld.startRound(now)
+		return
+	}
+	wrld.mode.Tick(wrld, now)
+	if wrld.mode.Over(wrld, now) || !now.Before(wrld.round.ends) {
+		wrld.endRound(now)
+	}
+}
+
+func (wrld *world) startRound(now time.Time) {
+	wrld.round.number++
+	wrld.round.running = true
+	wrld.round.ends = now.Add(wrld.round.length)
+	wrld.mode.Start(wrld, now)
+	for _, id := range wrld.players() {
+		wrld.mode.Join(wrld, id)
+	}
+	wrld.announce(fmt.Sprintf("round %d of %s: %s", wrld.round.number, wrld.mode.Name(), wrld.mode.Goal()))
+}
+
+func (wrld *world) endRound(now time.Time) {
+	wrld.round.running = false
+	wrld.round.next = now.Add(roundBreak)
+	winner, lines := wrld.mode.Results(wrld)
+	wrld.round.results = resultsModal(wrld.round.number, wrld.mode.Name(), winner, lines)
+	wrld.announce(fmt.Sprintf("round %d is over, %s wins", wrld.round.number, winner))
+
+	for _, id := range wrld.players() {
+		tmpUser := wrld.users[id]
+		if tmpUser.out {
+			// those knocked out come back at a spawn point
+			tmpUser.out = false
+			tmpUser.position = wrld.locations[0].randomSpawn()
+			tmpUser.protectedUntil = now.Add(spawnProtection)
+		}
+		// teams only hold for the round
+		tmpUser.team = ""
+		tmpUser.modal = loadModal(wrld.round.results)
+		tmpUser.activeModal = "results"
+		wrld.users[id] = tmpUser
+	}
+}
+
+// modeJoin hands a new player to the running round. Called with the world
+// locked, like every hook
+func (wrld *world) modeJoin(userID string) {
+	if wrld.mode != nil && wrld.round.running {
+		wrld.mode.Join(wrld, userID)
+	}
+}
+
+// modeDeath tells the running round about a death
+func (wrld *world) modeDeath(attackerID, victimID string, where position) {
+	if wrld.mode != nil && wrld.round.running {
+		wrld.mode.Death(wrld, attackerID, victimID, where)
+	}
+}
+
+// modeGlyph draws the running round's entities on a cell
+func (wrld *world) modeGlyph(cell string) (rune, string, bool) {
+	if wrld.mode == nil || !wrld.round.running {
+		return 0, styleNone, false
+	}
+	return wrld.mode.Glyph(wrld, cell)
+}
+
+// modeStatus is the round's part of the user's HUD
+func (wrld *world) modeStatus(userID string, now time.Time) string {
+	if wrld.mode == nil {
+		return ""
+	}
+	if !wrld.round.running {
+		if l, ok := wrld.mode.(lobby); ok && !now.Before(wrld.round.next) {
+			_, waiting := l.Ready(wrld)
+			return waiting
+		}
+		return fmt.Sprintf("next round in %ds", int(wrld.round.next.Sub(now).Seconds())+1)
+	}
+	left := wrld.round.ends.Sub(now)
+	status := fmt.Sprintf("%d:%02d", int(left.Minutes()), int(left.Seconds())%60)
+	if s := wrld.mode.Status(wrld, userID); s != "" {
+		status = s + " " + status
+	}
+	return status
+}
+
+// roundCommand handles `round`: the state of the current round, showing the
+// last round's results when there are any
+func (wrld *world) roundCommand(userID string, now time.Time) (string, error) {
+	if wrld.mode == nil {
+		return "", fmt.Errorf("no game mode, it's a free-for-all")
+	}
+	if wrld.round.results != "" {
+		tmpUser := wrld.users[userID]
+		tmpUser.modal = loadModal(wrld.round.results)
+		tmpUser.activeModal = "results"
+		wrld.users[userID] = tmpUser
+	}
+	if !wrld.round.running {
+		return fmt.Sprintf("%s: %s", wrld.mode.Name(), wrld.modeStatus(userID, now)), nil
+	}
+	return fmt.Sprintf("round %d of %s: %s", wrld.round.number, wrld.mode.Name(), wrld.modeStatus(userID, now)), nil
+}
+
+// announce sends a notice to every player
+func (wrld *world) announce(text string) {
+	for _, id := range wrld.players() {
+		wrld.notify(id, text)
+	}
+}
+
+// players lists the ids of everyone who isn't a monster, in a stable order
+func (wrld *world) players() []string {
+	ids := make([]string, 0)
+	for id, u := range wrld.users {
+		if !u.isNPC {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// teleport moves the user to the free cell nearest p, leaving their old
+// cell empty
+func (wrld *world) teleport(userID string, p position) {
+	loc := &wrld.locations[0]
+	tmpUser := wrld.users[userID]
+	if pos, ok := loc.positions[tmpUser.position.String()]; ok && pos.userID == userID {
+		pos.closed = false
+		pos.userID = ""
+	}
+	tmpUser.position = loc.freeCellNear(p)
+	if pos, ok := loc.positions[tmpUser.position.String()]; ok {
+		pos.closed = true
+		pos.userID = userID
+	}
+	wrld.users[userID] = tmpUser
+}
+
+// freeCellNear finds the open, unoccupied cell closest to p, searching
+// outward ring by ring. p itself is returned when nothing is free
+func (loc *location) freeCellNear(p position) position {
+	for r := 0; r <= 5; r++ {
+		for dy := -r; dy <= r; dy++ {
+			for dx := -r; dx <= r; dx++ {
+				if abs(dx) != r && abs(dy) != r {
+					continue
+				}
+				pos, ok := loc.positions[fmt.Sprintf("%d,%d", p.x+dx, p.y+dy)]
+				if ok && !pos.closed && pos.userID == "" {
+					return position{x: pos.x, y: pos.y}
+				}
+			}
+		}
+	}
+	return p
+}
+
+// standing is one line of a round's results
+type standing struct {
+	name  string
+	score int
+}
+
+// rank sorts scores best first, ties by name, and names the winner, or
+// "nobody" when the top is tied or nobody scored
+func rank(scores map[string]int) (string, []standing) {
+	standings := make([]standing, 0, len(scores))
+	for name, score := range scores {
+		standings = append(standings, standing{name, score})
+	}
+	sort.Slice(standings, func(i, j int) bool {
+		if standings[i].score != standings[j].score {
+			return standings[i].score > standings[j].score
+		}
+		return standings[i].name < standings[j].name
+	})
+	if len(standings) == 0 || standings[0].score == 0 ||
+		(len(standings) > 1 && standings[1].score == standings[0].score) {
+		return "nobody", standings
+	}
+	return standings[0].name, standings
+}
+
+func resultsModal(number int, mode, winner string, lines []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `
+┌────────────────────────────┐
+│ Round %3d %-16.16s │▒
+╞════════════════════════════╡▒
+│ Winner: %-18.18s │▒
+│                            │▒
+`, number, mode, winner)
+	if len(lines) > maxResultLines {
+		lines = lines[:maxResultLines]
+	}
+	for _, line := range lines {
+		fmt.Fprintf(&b, "│ %-26.26s │▒\n", line)
+	}
+	b.WriteString(`└────────────────────────────┘▒
+ ▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒
+`)
+	return b.String()
+}
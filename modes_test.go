@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRounds(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 1)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	if _, err := w.roundCommand("testingUser", time.Now()); err == nil {
+		t.Error("expected no rounds without a game mode")
+	}
+
+	mode, err := w.newGameMode("koth", modeConfig{Hill: rect{X1: 2, Y1: 2, X2: 4, Y2: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.setMode(mode, time.Minute)
+	now := time.Now()
+	w.tickMode(now)
+	if !w.round.running || w.round.number != 1 {
+		t.Fatalf("expected the first round to start, got %+v", w.round)
+	}
+	notices := w.users["testingUser"].notices
+	if len(notices) == 0 || !strings.HasPrefix(notices[len(notices)-1].text, "round 1 of king of the hill") {
+		t.Errorf("expected the round announced, got %v", notices)
+	}
+	if got := w.modeStatus("testingUser", now); !strings.HasSuffix(got, "1:00") {
+		t.Errorf("expected the time left in the status, got %q", got)
+	}
+
+	// time runs out
+	w.tickMode(now.Add(time.Minute))
+	if w.round.running {
+		t.Fatal("expected the round to end when time runs out")
+	}
+	if got := w.users["testingUser"].activeModal; got != "results" {
+		t.Errorf("expected the results shown, got modal %q", got)
+	}
+	if !strings.Contains(w.round.results, "nobody") {
+		t.Errorf("expected nobody to win an empty round:\n%s", w.round.results)
+	}
+
+	w.tickMode(now.Add(time.Minute + time.Second))
+	if w.round.running {
+		t.Error("expected a break between rounds")
+	}
+	w.tickMode(now.Add(time.Minute + roundBreak))
+	if !w.round.running || w.round.number != 2 {
+		t.Errorf("expected the second round after the break, got %+v", w.round)
+	}
+	if msg, _ := w.roundCommand("testingUser", now.Add(time.Minute+roundBreak)); !strings.HasPrefix(msg, "round 2 of king of the hill") {
+		t.Errorf("unexpected round status %q", msg)
+	}
+}
+
+func TestMapModes(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_2.map", 0, 1)
+	meta := loadMapMeta("maps/map_2.map")
+	for _, name := range []string{"ctf", "koth", "survival", "royale"} {
+		cfg, ok := meta.Modes[name]
+		if !ok {
+			t.Errorf("expected map_2 to configure %s", name)
+			continue
+		}
+		if _, err := w.newGameMode(name, cfg); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := w.newGameMode("tag", modeConfig{}); err == nil {
+		t.Error("expected unknown modes to be refused")
+	}
+}
+
+func TestRank(t *testing.T) {
+	winner, standings := rank(map[string]int{"b": 2, "a": 2, "c": 5})
+	if winner != "c" || standings[1].name != "a" {
+		t.Errorf("unexpected ranking %s %v", winner, standings)
+	}
+	if winner, _ := rank(map[string]int{"a": 2, "b": 2}); winner != "nobody" {
+		t.Errorf("expected a tie to have no winner, got %s", winner)
+	}
+}
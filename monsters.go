@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+const monstersPath = "data/monsters.json"
+
+// monster behaviors, see monsterIntent
+const (
+	behaviorHunter  = "hunter"  // chases players, flees when low on life
+	behaviorBerserk = "berserk" // chases players and never flees
+	behaviorCoward  = "coward"  // runs from any player it sees
+	behaviorPassive = "passive" // ignores players
+)
+
+// monsterType is an archetype declared in data/monsters.json
+type monsterType struct {
+	Name     string `json:"-"`
+	Glyph    string `json:"glyph"`
+	MaxLife  int    `json:"max_life"`
+	Damage   int    `json:"damage"`
+	Speed    int    `json:"speed_ms"`
+	Aggro    int    `json:"aggro_radius"`
+	Behavior string `json:"behavior"`
+	// experience awarded for the kill
+	XP int `json:"xp"`
+	// optional SGR parameters used in color mode instead of plain red
+	Color string `json:"color"`
+	// default chance of appearing when a map has no spawn table
+	Weight int `json:"weight"`
+	// items dropped on death, see data/items.json
+	Loot []lootEntry `json:"loot"`
+	// most gold dropped on death, see economy.go
+	Gold int `json:"gold"`
+	// status effect its attacks may inflict, see effects.go
+	OnHit *effectSpec `json:"on_hit"`
+	// set for bosses, which only spawn in lairs, see bosses.go
+	Boss *bossSpec `json:"boss"`
+}
+
+// spawnEntry is one row of a location's spawn table
+type spawnEntry struct {
+	Type   string `json:"type"`
+	Weight int    `json:"weight"`
+}
+
+func loadMonsterTypes(path string) map[string]monsterType {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	types := make(map[string]monsterType)
+	if err := json.Unmarshal(b, &types); err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	for name, kind := range types {
+		kind.Name = name
+		if kind.Behavior == "" {
+			kind.Behavior = behaviorHunter
+		}
+		if kind.Glyph == "" {
+			log.Fatalf("%s: monster %q has no glyph", path, name)
+		}
+		if kind.Speed <= 0 || kind.MaxLife <= 0 {
+			log.Fatalf("%s: monster %q needs a speed_ms and max_life above 0", path, name)
+		}
+		if kind.OnHit != nil {
+			if err := kind.OnHit.validate(); err != nil {
+				log.Fatalf("%s: monster %q: %v", path, name, err)
+			}
+		}
+		if kind.Boss != nil {
+			if err := kind.Boss.validate(); err != nil {
+				log.Fatalf("%s: boss %q: %v", path, name, err)
+			}
+		}
+		types[name] = kind
+	}
+	return types
+}
+
+// defaultSpawnTable is used by locations without one of their own
+func defaultSpawnTable(types map[string]monsterType) []spawnEntry {
+	table := make([]spawnEntry, 0, len(types))
+	for name, kind := range types {
+		if kind.Boss != nil {
+			// bosses keep to their lairs
+			continue
+		}
+		table = append(table, spawnEntry{Type: name, Weight: kind.Weight})
+	}
+	// map order is random; keep rolls reproducible for a given seed
+	sort.Slice(table, func(i, j int) bool { return table[i].Type < table[j].Type })
+	return table
+}
+
+// pickMonsterType rolls a spawn table
+func (wrld *world) pickMonsterType(table []spawnEntry) (monsterType, bool) {
+	total := 0
+	for _, entry := range table {
+		total += entry.Weight
+	}
+	if total <= 0 {
+		return monsterType{}, false
+	}
+	roll := rand.Intn(total)
+	for _, entry := range table {
+		if roll < entry.Weight {
+			kind, ok := wrld.monsterTypes[entry.Type]
+			return kind, ok
+		}
+		roll -= entry.Weight
+	}
+	return monsterType{}, false
+}
+
+// applyMonsterType turns the user into a monster of the given kind
+func (u *user) applyMonsterType(kind monsterType) {
+	u.kind = kind.Name
+	u.character = []rune(kind.Glyph)[0]
+	u.maxLife = kind.MaxLife
+	u.life = kind.MaxLife
+	u.damage = kind.Damage
+	u.speed = time.Duration(kind.Speed) * time.Millisecond
+	u.sightRadius = kind.Aggro
+	u.behavior = kind.Behavior
+}
+
+// createMonster adds a monster of the given kind rather than one rolled
+// from the location's spawn table
+func (wrld *world) createMonster(mID string, pos position, kind monsterType) bool {
+	if !wrld.createUser(mID, 80, 20, pos, true) {
+		return false
+	}
+	tmpUser := wrld.users[mID]
+	tmpUser.applyMonsterType(kind)
+	wrld.users[mID] = tmpUser
+	return true
+}
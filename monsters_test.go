@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+)
+
+func TestMonsterTypes(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 100)
+
+	for i := 0; i < 50; i++ {
+		kind, ok := w.pickMonsterType(w.locations[0].spawnTable)
+		if !ok {
+			t.Fatal("expected the default spawn table to pick a monster")
+		}
+		if kind.Name == "dragon" {
+			t.Fatal("dragons have no default weight and should not spawn on map_1")
+		}
+	}
+
+	if !w.createMonster("rat", position{x: 2, y: 3}, w.monsterTypes["rat"]) {
+		t.Fatal("unable to create monster")
+	}
+	rat := w.users["rat"]
+	if rat.character != 'r' || rat.life != w.monsterTypes["rat"].MaxLife || rat.kind != "rat" {
+		t.Errorf("monster does not match its archetype: %+v", rat)
+	}
+}
+
+func TestLocationSpawnTable(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_2.map", 0, 1)
+	if len(w.locations[0].spawnTable) != 6 {
+		t.Errorf("expected map_2's own spawn table, got %v", w.locations[0].spawnTable)
+	}
+}
+
+func TestMonsterBehaviors(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 3)
+	w.createUser("testingUser", 80, 20, position{x: 8, y: 4}, false)
+	w.createMonster("rat", position{x: 10, y: 4}, w.monsterTypes["rat"])
+	w.createMonster("bat", position{x: 11, y: 4}, w.monsterTypes["bat"])
+
+	// cowards run at full life
+	got := w.monsterIntent("rat")
+	if fled := applyMove(w.users["rat"].position, got); manhattan(fled, position{x: 8, y: 4}) <= 2 {
+		t.Errorf("expected the rat to flee, got %q", got)
+	}
+
+	w.monsterIntent("bat")
+	if target := w.users["bat"].target; target != "" {
+		t.Errorf("passive monsters should not target players, got %q", target)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import "time"
+
+const (
+	// notices kept per user
+	maxNotices = 5
+	// most recent notices drawn at the top of the viewport
+	noticeLines = 3
+	noticeTTL   = time.Second * 10
+)
+
+// notice is a line of text for one user: chat, invites, announcements
+type notice struct {
+	text string
+	at   time.Time
+}
+
+// notify queues a notice for the user. Called with the world locked
+func (wrld *world) notify(userID, text string) {
+	tmpUser, ok := wrld.users[userID]
+	if !ok || tmpUser.isNPC {
+		return
+	}
+	tmpUser.notices = append(tmpUser.notices, notice{text: text, at: time.Now()})
+	if len(tmpUser.notices) > maxNotices {
+		tmpUser.notices = tmpUser.notices[len(tmpUser.notices)-maxNotices:]
+	}
+	wrld.users[userID] = tmpUser
+}
+
+// noticeRows lays out the user's recent notices by viewport row, below the
+// HUD when it is at the top. Rows hold only the text, so the map shows
+// through to the right of it
+func (u *user) noticeRows(now time.Time, width int) map[int][]rune {
+	rows := make(map[int][]rune)
+	row := 1
+	if u.hud == hudTop {
+		row = 2
+	}
+
+	recent := make([]notice, 0, noticeLines)
+	for _, n := range u.notices {
+		if now.Sub(n.at) < noticeTTL {
+			recent = append(recent, n)
+		}
+	}
+	if len(recent) > noticeLines {
+		recent = recent[len(recent)-noticeLines:]
+	}
+
+	for _, n := range recent {
+		cells := textCells(" " + n.text + " ")
+		if len(cells) > width {
+			cells = fitCells(cells, width)
+		}
+		rows[row] = cells
+		row++
+	}
+	return rows
+}
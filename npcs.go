@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// townsfolk roles
+const (
+	roleQuestGiver = "quests" // hands out quests, see quests.go
+	roleShopkeeper = "shop"   // buys and sells, see economy.go
+)
+
+// metaNPC is one of the townsfolk, declared in a map sidecar. Unlike
+// monsters they stand still, can't be hurt and are talked to with `talk`
+type metaNPC struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Glyph string `json:"glyph"`
+	Role  string `json:"role"`
+	// what a shopkeeper trades in, see data/shops.json
+	Shop string `json:"shop"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+}
+
+// createNPC puts one of the townsfolk on the map. They are users like
+// monsters are, but have no goroutine of their own
+func (wrld *world) createNPC(def metaNPC) error {
+	switch def.Role {
+	case roleQuestGiver:
+	case roleShopkeeper:
+		if _, ok := wrld.shopTypes[def.Shop]; !ok {
+			return fmt.Errorf("npc %q keeps unknown shop %q", def.ID, def.Shop)
+		}
+	default:
+		return fmt.Errorf("npc %q has unknown role %q", def.ID, def.Role)
+	}
+	if def.ID == "" || stringWidth(def.Glyph) != 1 {
+		return fmt.Errorf("npc %q needs an id and a one column glyph", def.ID)
+	}
+	if _, ok := wrld.users[def.ID]; ok {
+		return fmt.Errorf("npc %q is declared twice", def.ID)
+	}
+	loc := &wrld.locations[0]
+	at := position{x: def.X, y: def.Y}
+	if pos, ok := loc.positions[at.String()]; !ok || pos.closed {
+		return fmt.Errorf("npc %q is not on an open cell", def.ID)
+	}
+	if def.Name == "" {
+		def.Name = def.ID
+	}
+
+	wrld.users[def.ID] = user{
+		userID:    def.ID,
+		position:  at,
+		character: []rune(def.Glyph)[0],
+		isNPC:     true,
+		role:      def.Role,
+		name:      def.Name,
+		shop:      def.Shop,
+		life:      1,
+		maxLife:   1,
+		level:     1,
+		equipment: make(map[string]string),
+	}
+	loc.markCells([]string{at.String()}, def.ID)
+	loc.npcs = append(loc.npcs, def.ID)
+	return nil
+}
+
+// npcBeside finds the townsfolk standing next to the user, "" when there
+// are none
+func (wrld *world) npcBeside(userID string) string {
+	u := wrld.users[userID]
+	ids := append([]string(nil), wrld.locations[0].npcs...)
+	sort.Strings(ids)
+	for _, id := range ids {
+		if adjacent(u.position, wrld.users[id].position) {
+			return id
+		}
+	}
+	return ""
+}
+
+// talk handles `talk`: speaking to the townsfolk next to the user, who
+// answer according to their role
+func (wrld *world) talk(userID string, args []string) (string, error) {
+	npcID := wrld.npcBeside(userID)
+	if npcID == "" {
+		return "", fmt.Errorf("nobody to talk to, stand next to them")
+	}
+	switch wrld.users[npcID].role {
+	case roleQuestGiver:
+		return wrld.questTalk(userID, npcID)
+	case roleShopkeeper:
+		return wrld.shopTalk(userID, npcID)
+	}
+	return "", fmt.Errorf("%s has nothing to say", wrld.users[npcID].name)
+}
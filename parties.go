@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// allyGlyph stands in for a party or team member's own glyph for clients
+// without color, which can't be shown the ally highlight
+const allyGlyph = '@'
+
+// partyMembers lists the users in a party in a stable order
+func (wrld *world) partyMembers(party string) []string {
+	members := make([]string, 0)
+	if party == "" {
+		return members
+	}
+	for id, u := range wrld.users {
+		if u.party == party {
+			members = append(members, id)
+		}
+	}
+	sort.Strings(members)
+	return members
+}
+
+// findPlayer looks a player up by id. Console commands arrive lower cased,
+// so it falls back to ignoring case
+func (wrld *world) findPlayer(name string) (string, bool) {
+	if u, ok := wrld.users[name]; ok {
+		return name, !u.isNPC
+	}
+	for id, u := range wrld.users {
+		if !u.isNPC && strings.EqualFold(id, name) {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// allies reports whether two users are on the same side: in the same
+// party or team, or both monsters
+func (wrld *world) allies(a, b string) bool {
+	ua, okA := wrld.users[a]
+	ub, okB := wrld.users[b]
+	if !okA || !okB || a == b {
+		return false
+	}
+	if ua.isNPC && ub.isNPC {
+		return true
+	}
+	return (ua.party != "" && ua.party == ub.party) || (ua.team != "" && ua.team == ub.team)
+}
+
+// partyCommand handles `party`: invite, accept, leave and say, or the
+// member list without arguments. The arguments are as typed, not lower
+// cased, for the sake of chat
+func (wrld *world) partyCommand(userID string, args []string) (string, error) {
+	tmpUser := wrld.users[userID]
+	if len(args) == 0 {
+		if tmpUser.party == "" {
+			return "not in a party", nil
+		}
+		return fmt.Sprintf("party: %s", strings.Join(wrld.partyMembers(tmpUser.party), ", ")), nil
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "invite":
+		if len(args) != 2 {
+			return "", fmt.Errorf("usage: party invite <user>")
+		}
+		inviteeID, ok := wrld.findPlayer(args[1])
+		if !ok || inviteeID == userID {
+			return "", fmt.Errorf("no player %q", args[1])
+		}
+		invitee := wrld.users[inviteeID]
+		if invitee.party != "" && invitee.party == tmpUser.party {
+			return "", fmt.Errorf("%s is already in your party", inviteeID)
+		}
+		invitee.partyInvite = userID
+		wrld.users[inviteeID] = invitee
+		wrld.notify(inviteeID, fmt.Sprintf("%s invites you to their party, :party accept to join", userID))
+		return fmt.Sprintf("invited %s", inviteeID), nil
+
+	case "accept":
+		if tmpUser.partyInvite == "" {
+			return "", fmt.Errorf("no party invite")
+		}
+		inviterID := tmpUser.partyInvite
+		tmpUser.partyInvite = ""
+		wrld.users[userID] = tmpUser
+		inviter, ok := wrld.users[inviterID]
+		if !ok {
+			return "", fmt.Errorf("%s has left", inviterID)
+		}
+		if inviter.party != "" && inviter.party == tmpUser.party {
+			return "", fmt.Errorf("already in a party with %s", inviterID)
+		}
+		// the party only comes about once someone joins it
+		if inviter.party == "" {
+			wrld.parties++
+			inviter.party = fmt.Sprintf("party-%d", wrld.parties)
+			wrld.users[inviterID] = inviter
+		}
+		wrld.leaveParty(userID)
+		tmpUser = wrld.users[userID]
+		tmpUser.party = inviter.party
+		wrld.users[userID] = tmpUser
+		wrld.partySay(userID, "joined the party")
+		return fmt.Sprintf("joined %s", strings.Join(wrld.partyMembers(tmpUser.party), ", ")), nil
+
+	case "leave":
+		if tmpUser.party == "" {
+			return "", fmt.Errorf("not in a party")
+		}
+		wrld.partySay(userID, "left the party")
+		wrld.leaveParty(userID)
+		return "left the party", nil
+
+	case "say":
+		if tmpUser.party == "" {
+			return "", fmt.Errorf("not in a party")
+		}
+		if len(args) < 2 {
+			return "", fmt.Errorf("usage: party say <message>")
+		}
+		wrld.partySay(userID, strings.Join(args[1:], " "))
+		return "", nil
+	}
+	return "", fmt.Errorf("usage: party [invite <user>|accept|leave|say <message>]")
+}
+
+// leaveParty takes the user out of their party, disbanding it when only one
+// member would be left
+func (wrld *world) leaveParty(userID string) {
+	tmpUser := wrld.users[userID]
+	party := tmpUser.party
+	if party == "" {
+		return
+	}
+	tmpUser.party = ""
+	wrld.users[userID] = tmpUser
+
+	if rest := wrld.partyMembers(party); len(rest) == 1 {
+		last := wrld.users[rest[0]]
+		last.party = ""
+		wrld.users[rest[0]] = last
+		wrld.notify(rest[0], "your party has disbanded")
+	}
+}
+
+// partySay sends a chat line to everyone in the user's party
+func (wrld *world) partySay(userID, text string) {
+	for _, id := range wrld.partyMembers(wrld.users[userID].party) {
+		wrld.notify(id, fmt.Sprintf("[party] %s: %s", userID, text))
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParty(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 3)
+	w.createUser("Alice", 80, 20, position{x: 2, y: 3}, false)
+	w.createUser("Bob", 80, 20, position{x: 3, y: 3}, false)
+	w.createMonster("goblin", position{x: 2, y: 4}, w.monsterTypes["goblin"])
+
+	if _, err := w.partyCommand("Bob", []string{"accept"}); err == nil {
+		t.Error("expected accepting without an invite to fail")
+	}
+	if _, err := w.partyCommand("Alice", []string{"invite", "goblin"}); err == nil {
+		t.Error("expected monsters not to be invited")
+	}
+	// console commands arrive lower cased
+	if _, err := w.partyCommand("Alice", []string{"invite", "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(w.users["Bob"].notices); n != 1 || !strings.Contains(w.users["Bob"].notices[0].text, "Alice") {
+		t.Errorf("expected Bob to be told about the invite, got %v", w.users["Bob"].notices)
+	}
+	if w.users["Alice"].party != "" {
+		t.Error("expected no party before the invite is accepted")
+	}
+	if _, err := w.partyCommand("Bob", []string{"accept"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg, _ := w.partyCommand("Bob", nil); msg != "party: Alice, Bob" {
+		t.Errorf("unexpected members %q", msg)
+	}
+	if !w.allies("Alice", "Bob") || w.allies("Alice", "goblin") {
+		t.Error("expected party members, and only them, to be allies")
+	}
+
+	w.partyCommand("Alice", []string{"say", "hello", "there"})
+	notices := w.users["Bob"].notices
+	if got := notices[len(notices)-1].text; got != "[party] Alice: hello there" {
+		t.Errorf("unexpected chat %q", got)
+	}
+
+	if _, err := w.partyCommand("Bob", []string{"leave"}); err != nil {
+		t.Fatal(err)
+	}
+	if w.users["Alice"].party != "" {
+		t.Error("expected a party of one to disband")
+	}
+}
+
+func TestFriendlyFire(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("Alice", 80, 20, position{x: 2, y: 3}, false)
+	w.createUser("Bob", 80, 20, position{x: 3, y: 3}, false)
+	w.partyCommand("Alice", []string{"invite", "Bob"})
+	w.partyCommand("Bob", []string{"accept"})
+	fightable(w, "Alice", "Bob")
+
+	life := w.users["Bob"].life
+	w.damageUser("Alice", "Bob", 1)
+	if got := w.users["Bob"].life; got != life {
+		t.Errorf("expected party members to be spared, life %d", got)
+	}
+
+	w.friendlyFire = true
+	w.damageUser("Alice", "Bob", 1)
+	if got := w.users["Bob"].life; got != life-1 {
+		t.Errorf("expected friendly fire to hurt, life %d", got)
+	}
+}
+
+func TestSharedXP(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 3)
+	w.createUser("Alice", 80, 20, position{x: 2, y: 3}, false)
+	w.createUser("Bob", 80, 20, position{x: 3, y: 3}, false)
+	w.createMonster("troll", position{x: 2, y: 4}, w.monsterTypes["troll"])
+	w.partyCommand("Alice", []string{"invite", "Bob"})
+	w.partyCommand("Bob", []string{"accept"})
+
+	if !w.damageUser("Alice", "troll", 100) {
+		t.Fatal("expected the troll to die")
+	}
+	xp := w.monsterTypes["troll"].XP
+	if got := w.users["Bob"].xp; got != xp/2 {
+		t.Errorf("expected Bob to get half the xp, got %d want %d", got, xp/2)
+	}
+	if got := w.users["Alice"].xp; got != xp-xp/2 {
+		t.Errorf("expected Alice to get the rest, got %d want %d", got, xp-xp/2)
+	}
+}
+
+func TestNoticeRows(t *testing.T) {
+	u := user{hud: hudTop}
+	now := time.Now()
+	for _, text := range []string{"old", "one", "two", "three"} {
+		u.notices = append(u.notices, notice{text: text, at: now})
+	}
+	u.notices[0].at = now.Add(-noticeTTL)
+
+	rows := u.noticeRows(now, 6)
+	if len(rows) != noticeLines {
+		t.Fatalf("expected %d rows, got %d", noticeLines, len(rows))
+	}
+	if got := string(rows[2]); got != " one " {
+		t.Errorf("expected the first notice below the HUD, got %q", got)
+	}
+	if got := string(rows[4]); got != " three" {
+		t.Errorf("expected long notices cut to width, got %q", got)
+	}
+}
+
+func TestPartyChatKeepsCase(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("Alice", 80, 20, position{x: 2, y: 3}, false)
+	w.createUser("Bob", 80, 20, position{x: 3, y: 3}, false)
+
+	step(w, "Alice", ">party invite bob")
+	step(w, "Bob", ">Party Accept")
+	step(w, "Alice", ">party say Meet at the Elder")
+	notices := w.users["Bob"].notices
+	if got := notices[len(notices)-1].text; got != "[party] Alice: Meet at the Elder" {
+		t.Errorf("expected the message as typed, got %q", got)
+	}
+}
+
+func TestAllyHighlight(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("Alice", 40, 10, position{x: 2, y: 3}, false)
+	w.createUser("Bob", 40, 10, position{x: 3, y: 3}, false)
+	w.locations[0].positions["2,3"].userID = "Alice"
+	w.locations[0].positions["3,3"].userID = "Bob"
+	tmpUser := w.users["Alice"]
+	tmpUser.modal = loadModal("")
+	w.users["Alice"] = tmpUser
+
+	if strings.ContainsRune(string(w.display("Alice", 40, 10)), allyGlyph) {
+		t.Fatal("expected no ally marker outside a party")
+	}
+	w.partyCommand("Alice", []string{"invite", "Bob"})
+	w.partyCommand("Bob", []string{"accept"})
+	if !strings.ContainsRune(string(w.display("Alice", 40, 10)), allyGlyph) {
+		t.Error("expected plain clients to see party members marked")
+	}
+
+	tmpUser = w.users["Alice"]
+	tmpUser.color = true
+	w.users["Alice"] = tmpUser
+	ally := "\x1b[" + styleAlly + playerStyle("Bob") + "m" + string(narrowGlyph(w.users["Bob"].character))
+	if colored := string(w.display("Alice", 40, 10)); !strings.Contains(colored, ally) {
+		t.Errorf("expected the highlighted ally %q in %q", ally, colored)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	profilesPath = "profiles.json"
+	// how often changed profiles are written out
+	profileSaveEvery = time.Second * 30
+)
+
+// playerProfile is what is kept about a player between sessions
+type playerProfile struct {
+	Kills         int `json:"kills"`
+	Deaths        int `json:"deaths"`
+	MonstersSlain int `json:"monsters_slain"`
+	// seconds
+	LongestLife int       `json:"longest_life"`
+	LastSeen    time.Time `json:"last_seen"`
+	// when each achievement was unlocked, see achievements.go
+	Achievements map[string]time.Time `json:"achievements"`
+	// when each quest was finished, see quests.go
+	Quests map[string]time.Time `json:"quests"`
+	// when each recipe was learned, see crafting.go
+	Recipes map[string]time.Time `json:"recipes"`
+}
+
+// profileStore keeps every player's profile, saved as JSON to path. An
+// empty path keeps them in memory only
+type profileStore struct {
+	path     string
+	profiles map[string]*playerProfile
+	dirty    bool
+	nextSave time.Time
+
+	// files are written off the world lock, one at a time, and never
+	// replaced by an older snapshot
+	writing sync.Mutex
+	taken   int
+	written int
+}
+
+func newProfileStore(path string) *profileStore {
+	return &profileStore{path: path, profiles: make(map[string]*playerProfile)}
+}
+
+// loadProfiles reads the store at path, starting empty when there is none
+func loadProfiles(path string) *profileStore {
+	s := newProfileStore(path)
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &s.profiles); err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	return s
+}
+
+// get returns the player's profile, creating it on first use. Changes to
+// it are saved with the next save
+func (s *profileStore) get(userID string) *playerProfile {
+	p, ok := s.profiles[userID]
+	if !ok {
+		p = &playerProfile{}
+		s.profiles[userID] = p
+	}
+	s.dirty = true
+	return p
+}
+
+// snapshot encodes the store when it has changed, returning nil when there
+// is nothing to write. Called with the world locked
+func (s *profileStore) snapshot() ([]byte, int, error) {
+	if s.path == "" || !s.dirty {
+		return nil, 0, nil
+	}
+	b, err := json.MarshalIndent(s.profiles, "", "  ")
+	if err != nil {
+		return nil, 0, err
+	}
+	s.dirty = false
+	s.taken++
+	return b, s.taken, nil
+}
+
+// write replaces the file with snapshot seq in one step, so a crash can't
+// leave half of it behind. It needs no world lock
+func (s *profileStore) write(b []byte, seq int) error {
+	s.writing.Lock()
+	defer s.writing.Unlock()
+	if seq <= s.written {
+		return nil
+	}
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.written = seq
+	return nil
+}
+
+// save writes the store out straight away when it has changed
+func (s *profileStore) save() error {
+	b, seq, err := s.snapshot()
+	if err != nil || b == nil {
+		return err
+	}
+	return s.write(b, seq)
+}
+
+// saveProfiles brings the profiles of everyone playing up to date and
+// saves them every so often. It is called from the game loop with the
+// world locked, so only the encoding happens there and the file is written
+// in the background
+func (wrld *world) saveProfiles(now time.Time) {
+	if now.Before(wrld.profiles.nextSave) {
+		return
+	}
+	wrld.profiles.nextSave = now.Add(profileSaveEvery)
+	wrld.updateProfiles(now)
+	b, seq, err := wrld.profiles.snapshot()
+	if err != nil {
+		log.Println("saving profiles:", err)
+		return
+	}
+	if b == nil {
+		return
+	}
+	go func(s *profileStore) {
+		if err := s.write(b, seq); err != nil {
+			log.Println("saving profiles:", err)
+		}
+	}(wrld.profiles)
+}
+
+// saveOnShutdown saves the profiles one last time when the server is told
+// to stop, so the stats since the last save aren't lost, then stops the CPU
+// profiler so its file is complete
+func (wrld *world) saveOnShutdown(prof interface{ Stop() }) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	<-c
+	wrld.Lock()
+	wrld.updateProfiles(time.Now())
+	if err := wrld.profiles.save(); err != nil {
+		log.Println("saving profiles:", err)
+	}
+	log.Println("profiles saved, stopping")
+	prof.Stop()
+	os.Exit(0)
+}
+
+// updateProfiles records when everyone playing was last seen and their
+// longest life so far
+func (wrld *world) updateProfiles(now time.Time) {
+	for _, id := range wrld.players() {
+		u := wrld.users[id]
+		p := wrld.profiles.get(id)
+		p.LastSeen = now
+		if life := int(u.longestLife(now).Seconds()); life > p.LongestLife {
+			p.LongestLife = life
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProfileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "profiles.json")
+
+	s := loadProfiles(path)
+	if len(s.profiles) != 0 {
+		t.Fatal("expected a missing store to start empty")
+	}
+	p := s.get("Alice")
+	p.Kills = 3
+	p.LongestLife = 90
+	if err := s.save(); err != nil {
+		t.Fatal(err)
+	}
+	if s.dirty {
+		t.Error("expected saving to clear the changes")
+	}
+
+	again := loadProfiles(path)
+	if got := again.profiles["Alice"]; got == nil || got.Kills != 3 || got.LongestLife != 90 {
+		t.Errorf("expected the profile to survive a restart, got %+v", got)
+	}
+}
+
+func TestSaveProfiles(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 1)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	now := time.Now()
+	tmpUser := w.users["testingUser"]
+	tmpUser.lifeStart = now.Add(-time.Minute)
+	w.users["testingUser"] = tmpUser
+
+	w.saveProfiles(now)
+	if got := w.profiles.profiles["testingUser"].LongestLife; got != 60 {
+		t.Errorf("expected the life under way saved, got %d", got)
+	}
+}
+
+func TestProfileWritesInOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "profiles.json")
+
+	s := loadProfiles(path)
+	s.get("Alice").Kills = 1
+	old, oldSeq, err := s.snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.get("Alice").Kills = 2
+	if err := s.save(); err != nil {
+		t.Fatal(err)
+	}
+	// a slow write of the first snapshot finishing late
+	if err := s.write(old, oldSeq); err != nil {
+		t.Fatal(err)
+	}
+
+	again := loadProfiles(path)
+	if got := again.profiles["Alice"]; got == nil || got.Kills != 2 {
+		t.Errorf("expected the newer snapshot kept, got %+v", got)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import "log"
+
+// projectile is something flying across the map, one cell per tick, until
+// it hits a closed tile, someone standing in its way, or runs out of range
+type projectile struct {
+	ownerID   string
+	x, y      int
+	dx, dy    int
+	remaining int
+	damage    int
+	glyph     rune
+}
+
+// attackDirections are the arguments `attack` takes to aim
+var attackDirections = map[string]point{
+	"w": {0, -1},
+	"a": {-1, 0},
+	"s": {0, 1},
+	"d": {1, 0},
+}
+
+// projectileGlyph is what an arrow looks like flying in a direction
+func projectileGlyph(dir point) rune {
+	if dir.x == 0 {
+		return '|'
+	}
+	return '-'
+}
+
+// launch fires a projectile from the owner's cell. It leaves on the next tick
+func (wrld *world) launch(ownerID string, dir point, distance, damage int, glyph rune) {
+	owner := wrld.users[ownerID]
+	wrld.projectiles = append(wrld.projectiles, &projectile{
+		ownerID:   ownerID,
+		x:         owner.position.x,
+		y:         owner.position.y,
+		dx:        dir.x,
+		dy:        dir.y,
+		remaining: distance,
+		damage:    damage,
+		glyph:     glyph,
+	})
+}
+
+// moveProjectiles advances every projectile a cell. It is called from the
+// game loop with the world locked
+func (wrld *world) moveProjectiles() {
+	loc := &wrld.locations[0]
+	flying := wrld.projectiles[:0]
+	for _, p := range wrld.projectiles {
+		if pos, ok := loc.positions[position{x: p.x, y: p.y}.String()]; ok && pos.projectile == p.glyph {
+			pos.projectile = 0
+		}
+
+		p.x += p.dx
+		p.y += p.dy
+		p.remaining--
+		pos, ok := loc.positions[position{x: p.x, y: p.y}.String()]
+		if !ok {
+			continue
+		}
+		if pos.userID != "" && pos.userID != p.ownerID {
+			log.Printf("projectile from %s hit %s at (%d,%d)", p.ownerID, pos.userID, p.x, p.y)
+			go areaAttack(pos)
+			wrld.damageUser(p.ownerID, pos.userID, p.damage)
+			continue
+		}
+		if pos.closed {
+			continue
+		}
+		if p.remaining <= 0 {
+			continue
+		}
+		pos.projectile = p.glyph
+		flying = append(flying, p)
+	}
+	// drop references to spent projectiles
+	for i := len(flying); i < len(wrld.projectiles); i++ {
+		wrld.projectiles[i] = nil
+	}
+	wrld.projectiles = flying
+}
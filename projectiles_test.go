@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+)
+
+// occupy marks a user's cell the way moving onto it does
+func occupy(w *world, userID string) {
+	pos := w.locations[0].positions[w.users[userID].position.String()]
+	pos.userID = userID
+	pos.closed = true
+}
+
+func TestDirectionalAttack(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 3)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 4}, false)
+	w.createMonster("east", position{x: 3, y: 4}, w.monsterTypes["goblin"])
+	w.createMonster("north", position{x: 2, y: 3}, w.monsterTypes["goblin"])
+	occupy(w, "east")
+	occupy(w, "north")
+
+	if _, err := w.attack("testingUser", []string{"x"}); err == nil {
+		t.Error("expected an unknown direction to fail")
+	}
+	if _, err := w.attack("testingUser", []string{"d"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := w.users["east"].life, w.monsterTypes["goblin"].MaxLife-1; got != want {
+		t.Errorf("expected the monster to the east to be hit, life %d want %d", got, want)
+	}
+	if got, want := w.users["north"].life, w.monsterTypes["goblin"].MaxLife; got != want {
+		t.Errorf("expected the monster to the north to be spared, life %d want %d", got, want)
+	}
+
+	// running out of energy is reported, not refused
+	tmpUser := w.users["testingUser"]
+	tmpUser.energy = 0
+	w.users["testingUser"] = tmpUser
+	if message, err := w.attack("testingUser", []string{"d"}); err != nil || message != "Not enough energy" {
+		t.Errorf("expected a plain message without energy, got %q, %v", message, err)
+	}
+}
+
+func TestProjectileHitsFirstOccupant(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 3)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 4}, false)
+	w.createMonster("near", position{x: 6, y: 4}, w.monsterTypes["goblin"])
+	w.createMonster("far", position{x: 8, y: 4}, w.monsterTypes["goblin"])
+	occupy(w, "near")
+	occupy(w, "far")
+
+	tmpUser := w.users["testingUser"]
+	tmpUser.equipment[slotWeapon] = "bow"
+	tmpUser.energy = tmpUser.maxEnergy
+	w.users["testingUser"] = tmpUser
+
+	if _, err := w.attack("testingUser", []string{"d"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.users["near"].life; got != w.monsterTypes["goblin"].MaxLife {
+		t.Error("expected the arrow to take time to arrive")
+	}
+
+	w.moveProjectiles()
+	if got := w.locations[0].positions["3,4"].projectile; got != '-' {
+		t.Errorf("expected the arrow drawn one cell out, got %q", got)
+	}
+	for i := 0; i < 3; i++ {
+		w.moveProjectiles()
+	}
+	if len(w.projectiles) != 0 {
+		t.Fatal("expected the arrow to stop at the first monster")
+	}
+	if got := w.locations[0].positions["5,4"].projectile; got != 0 {
+		t.Errorf("expected the arrow to be cleared from the map, got %q", got)
+	}
+	if got := w.users["near"].life; got != w.monsterTypes["goblin"].MaxLife-1 {
+		t.Errorf("expected the near monster to be hit, life %d", got)
+	}
+	if got := w.users["far"].life; got != w.monsterTypes["goblin"].MaxLife {
+		t.Errorf("expected the far monster to be spared, life %d", got)
+	}
+}
+
+func TestProjectileStoppedByWall(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 1)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 4}, false)
+	w.launch("testingUser", attackDirections["w"], 8, 1, '|')
+
+	// (2,3) and (2,2) are open, (2,1) is the outer wall
+	for i := 0; i < 3; i++ {
+		w.moveProjectiles()
+	}
+	if len(w.projectiles) != 0 {
+		t.Error("expected the wall to stop the projectile")
+	}
+	for _, cell := range []string{"2,3", "2,2", "2,1"} {
+		if got := w.locations[0].positions[cell].projectile; got != 0 {
+			t.Errorf("expected %s to be clear, got %q", cell, got)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	// time between changes of a player's pvp flag
+	pvpCooldown = time.Second * 30
+	// how long players can't be hurt after joining or respawning
+	spawnProtection = time.Second * 5
+)
+
+// rect is a rectangular region of a location, edges included
+type rect struct {
+	Name string `json:"name"`
+	X1   int    `json:"x1"`
+	Y1   int    `json:"y1"`
+	X2   int    `json:"x2"`
+	Y2   int    `json:"y2"`
+}
+
+func (r rect) contains(p position) bool {
+	return p.x >= r.X1 && p.x <= r.X2 && p.y >= r.Y1 && p.y <= r.Y2
+}
+
+// inSafeZone reports whether p is somewhere no damage can be dealt
+func (loc *location) inSafeZone(p position) bool {
+	for _, zone := range loc.safeZones {
+		if zone.contains(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// randomSpawn picks one of the location's player spawn points
+func (loc *location) randomSpawn() position {
+	return loc.spawns[rand.Intn(len(loc.spawns))]
+}
+
+// sheltered reports whether the user can't be hurt right now: they are in
+// a safe zone or still protected after spawning
+func (wrld *world) sheltered(userID string, now time.Time) bool {
+	u, ok := wrld.users[userID]
+	if !ok {
+		return false
+	}
+	return now.Before(u.protectedUntil) || wrld.locations[0].inSafeZone(u.position)
+}
+
+// canHurt reports whether the attacker may damage the victim. Nobody deals
+// or takes damage in a safe zone, freshly spawned players and townsfolk are
+// protected, and players only fight players when both have pvp on or the
+// round under way pits them against each other
+func (wrld *world) canHurt(attackerID, victimID string, now time.Time) bool {
+	if wrld.sheltered(victimID, now) || wrld.users[victimID].out || wrld.users[victimID].role != "" {
+		return false
+	}
+	attacker, ok := wrld.users[attackerID]
+	if !ok {
+		// tiles and the like
+		return true
+	}
+	if wrld.locations[0].inSafeZone(attacker.position) {
+		return false
+	}
+	victim := wrld.users[victimID]
+	if !attacker.isNPC && !victim.isNPC {
+		if wrld.mode != nil && wrld.round.running && wrld.mode.PvP() {
+			return true
+		}
+		return attacker.pvp && victim.pvp
+	}
+	return true
+}
+
+// setPvP handles `pvp`: on or off, or the current setting without arguments
+func (wrld *world) setPvP(userID string, args []string, now time.Time) (string, error) {
+	tmpUser := wrld.users[userID]
+	state := map[bool]string{true: "on", false: "off"}
+	if len(args) == 0 {
+		return fmt.Sprintf("pvp is %s", state[tmpUser.pvp]), nil
+	}
+
+	var on bool
+	switch args[0] {
+	case "on":
+		on = true
+	case "off":
+	default:
+		return "", fmt.Errorf("usage: pvp [on|off]")
+	}
+	if on == tmpUser.pvp {
+		return fmt.Sprintf("pvp is already %s", state[on]), nil
+	}
+	if wait := tmpUser.pvpChanged.Add(pvpCooldown).Sub(now); wait > 0 {
+		return "", fmt.Errorf("pvp can be changed again in %ds", int(wait.Seconds())+1)
+	}
+	tmpUser.pvp = on
+	tmpUser.pvpChanged = now
+	wrld.users[userID] = tmpUser
+	return fmt.Sprintf("pvp is now %s", state[on]), nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+)
+
+// fightable lifts spawn protection from players and opts them into pvp
+func fightable(w *world, userIDs ...string) {
+	for _, id := range userIDs {
+		tmpUser := w.users[id]
+		tmpUser.protectedUntil = time.Time{}
+		tmpUser.pvp = true
+		w.users[id] = tmpUser
+	}
+}
+
+func TestPvPFlag(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("Alice", 80, 20, position{x: 2, y: 3}, false)
+	w.createUser("Bob", 80, 20, position{x: 3, y: 3}, false)
+	fightable(w, "Alice", "Bob")
+
+	now := time.Now()
+	if _, err := w.setPvP("Bob", []string{"off"}, now); err != nil {
+		t.Fatal(err)
+	}
+	life := w.users["Bob"].life
+	w.damageUser("Alice", "Bob", 1)
+	if got := w.users["Bob"].life; got != life {
+		t.Errorf("expected players without pvp to be spared, life %d", got)
+	}
+
+	if _, err := w.setPvP("Bob", []string{"on"}, now.Add(time.Second)); err == nil {
+		t.Error("expected the pvp flag to have a cooldown")
+	}
+	if _, err := w.setPvP("Bob", []string{"on"}, now.Add(pvpCooldown)); err != nil {
+		t.Fatal(err)
+	}
+	w.damageUser("Alice", "Bob", 1)
+	if got := w.users["Bob"].life; got != life-1 {
+		t.Errorf("expected players with pvp to fight, life %d", got)
+	}
+}
+
+func TestSpawnProtection(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+	w.createMonster("troll", position{x: 2, y: 4}, w.monsterTypes["troll"])
+
+	life := w.users["testingUser"].life
+	w.damageUser("troll", "testingUser", 1)
+	if got := w.users["testingUser"].life; got != life {
+		t.Errorf("expected a new player to be protected, life %d", got)
+	}
+
+	fightable(w, "testingUser")
+	if !w.damageUser("troll", "testingUser", 100) {
+		t.Fatal("expected the player to die")
+	}
+	if !w.sheltered("testingUser", time.Now()) {
+		t.Error("expected a respawned player to be protected")
+	}
+
+	// attacking gives protection up
+	w.attack("testingUser", nil)
+	if w.sheltered("testingUser", time.Now()) {
+		t.Error("expected attacking to end spawn protection")
+	}
+}
+
+func TestSafeZone(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+	w.createMonster("troll", position{x: 2, y: 4}, w.monsterTypes["troll"])
+	fightable(w, "testingUser")
+	w.locations[0].safeZones = []rect{{Name: "camp", X1: 1, Y1: 1, X2: 3, Y2: 3}}
+
+	life := w.users["testingUser"].life
+	w.damageUser("troll", "testingUser", 1)
+	if got := w.users["testingUser"].life; got != life {
+		t.Errorf("expected no damage in a safe zone, life %d", got)
+	}
+	trollLife := w.users["troll"].life
+	w.damageUser("testingUser", "troll", 1)
+	if got := w.users["troll"].life; got != trollLife {
+		t.Errorf("expected no damage dealt from a safe zone, life %d", got)
+	}
+
+	w.Lock()
+	w.monsterIntent("troll")
+	w.Unlock()
+	if w.users["troll"].target != "" {
+		t.Error("expected players in a safe zone not to be hunted")
+	}
+
+	w.locations[0].safeZones = append(w.locations[0].safeZones, rect{X1: 3, Y1: 4, X2: 3, Y2: 4})
+	step(w, "troll", "md")
+	if got := w.users["troll"].position.x; got != 2 {
+		t.Errorf("expected the troll to keep out of the safe zone, x %d", got)
+	}
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	questsPath = "data/quests.json"
+	// text columns of the modals built by textModal
+	textModalWidth = 36
+)
+
+// questType is a quest declared in data/quests.json. It has exactly one
+// objective: kill monsters, reach a place or fetch items
+type questType struct {
+	ID   string `json:"-"`
+	Name string `json:"name"`
+	// the townsfolk who hands it out and takes it back, see npcs.go
+	Giver string `json:"giver"`
+	Text  string `json:"text"`
+	// a quest that has to be finished before this one is offered
+	Requires string `json:"requires"`
+
+	Kill  *questTarget `json:"kill"`
+	Reach *rect        `json:"reach"`
+	// fetched items are handed over when the quest is turned in
+	Fetch *questTarget `json:"fetch"`
+
+	Reward questReward `json:"reward"`
+}
+
+// questTarget is how many of a monster to slay or an item to fetch
+type questTarget struct {
+	Monster string `json:"monster"`
+	Item    string `json:"item"`
+	Count   int    `json:"count"`
+}
+
+type questReward struct {
+	XP    int      `json:"xp"`
+	Items []string `json:"items"`
+}
+
+func loadQuestTypes(path string) map[string]questType {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	quests := make(map[string]questType)
+	if err := json.Unmarshal(b, &quests); err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	for id, q := range quests {
+		q.ID = id
+		if q.Name == "" {
+			q.Name = id
+		}
+		objectives := 0
+		for _, set := range []bool{q.Kill != nil, q.Reach != nil, q.Fetch != nil} {
+			if set {
+				objectives++
+			}
+		}
+		if objectives != 1 {
+			log.Fatalf("%s: quest %q needs exactly one of kill, reach or fetch", path, id)
+		}
+		if (q.Kill != nil && (q.Kill.Monster == "" || q.Kill.Count <= 0)) ||
+			(q.Fetch != nil && (q.Fetch.Item == "" || q.Fetch.Count <= 0)) {
+			log.Fatalf("%s: quest %q needs what to kill or fetch and a count", path, id)
+		}
+		if q.Giver == "" {
+			log.Fatalf("%s: quest %q has no giver", path, id)
+		}
+		quests[id] = q
+	}
+	return quests
+}
+
+// validateQuests checks what the quests refer to exists
+func validateQuests(quests map[string]questType, monsters map[string]monsterType, items map[string]itemType) error {
+	for id, q := range quests {
+		if q.Kill != nil {
+			if _, ok := monsters[q.Kill.Monster]; !ok {
+				return fmt.Errorf("quest %q kills unknown monster %q", id, q.Kill.Monster)
+			}
+		}
+		if q.Fetch != nil {
+			if _, ok := items[q.Fetch.Item]; !ok {
+				return fmt.Errorf("quest %q fetches unknown item %q", id, q.Fetch.Item)
+			}
+		}
+		for _, item := range q.Reward.Items {
+			if _, ok := items[item]; !ok {
+				return fmt.Errorf("quest %q rewards unknown item %q", id, item)
+			}
+		}
+		if _, ok := quests[q.Requires]; q.Requires != "" && !ok {
+			return fmt.Errorf("quest %q requires unknown quest %q", id, q.Requires)
+		}
+	}
+	return nil
+}
+
+// validateGivers checks each quest is handed out by townsfolk on the map
+// who give quests. A map without any keeps its quests off the table
+func (wrld *world) validateGivers() error {
+	givers := make(map[string]bool)
+	for _, id := range wrld.locations[0].npcs {
+		if wrld.users[id].role == roleQuestGiver {
+			givers[id] = true
+		}
+	}
+	if len(givers) == 0 {
+		return nil
+	}
+	for id, q := range wrld.questTypes {
+		if !givers[q.Giver] {
+			return fmt.Errorf("quest %q is given by %q, who gives no quests here", id, q.Giver)
+		}
+	}
+	return nil
+}
+
+// objective describes what the quest asks for
+func (wrld *world) objective(q questType) string {
+	switch {
+	case q.Kill != nil:
+		return fmt.Sprintf("slay %s x%d", q.Kill.Monster, q.Kill.Count)
+	case q.Reach != nil:
+		return "reach " + q.Reach.Name
+	default:
+		return fmt.Sprintf("bring %s x%d", wrld.itemTypes[q.Fetch.Item].Name, q.Fetch.Count)
+	}
+}
+
+// questProgress is how far the user has got with a quest under way
+func (wrld *world) questProgress(userID string, q questType) (have, need int) {
+	u := wrld.users[userID]
+	switch {
+	case q.Kill != nil:
+		return u.quests[q.ID], q.Kill.Count
+	case q.Reach != nil:
+		return u.quests[q.ID], 1
+	default:
+		_, counts := itemCounts(u.inventory)
+		have = counts[q.Fetch.Item]
+		if have > q.Fetch.Count {
+			have = q.Fetch.Count
+		}
+		return have, q.Fetch.Count
+	}
+}
+
+// activeQuests lists the quests the user has under way, in a stable order
+func (u user) activeQuests() []string {
+	ids := make([]string, 0, len(u.quests))
+	for id := range u.quests {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// finished reports whether the player has ever turned the quest in
+func (wrld *world) finished(userID, questID string) bool {
+	p, ok := wrld.profiles.profiles[userID]
+	if !ok {
+		return false
+	}
+	_, ok = p.Quests[questID]
+	return ok
+}
+
+// nextQuest is the first quest the townsfolk has for the user that they
+// haven't taken on or finished, and are ready for
+func (wrld *world) nextQuest(userID, npcID string) (questType, bool) {
+	ids := make([]string, 0, len(wrld.questTypes))
+	for id := range wrld.questTypes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	u := wrld.users[userID]
+	for _, id := range ids {
+		q := wrld.questTypes[id]
+		if _, active := u.quests[id]; active || q.Giver != npcID || wrld.finished(userID, id) {
+			continue
+		}
+		if q.Requires != "" && !wrld.finished(userID, q.Requires) {
+			continue
+		}
+		return q, true
+	}
+	return questType{}, false
+}
+
+// questTalk turns in the user's finished quests from the quest giver and
+// hands out the next one, once nothing from them is under way
+func (wrld *world) questTalk(userID, npcID string) (string, error) {
+	giver := wrld.users[npcID]
+	lines := make([]string, 0)
+	pending := false
+	for _, id := range wrld.users[userID].activeQuests() {
+		q := wrld.questTypes[id]
+		if q.Giver != npcID {
+			continue
+		}
+		if have, need := wrld.questProgress(userID, q); have < need {
+			pending = true
+			lines = append(lines, fmt.Sprintf("%s: %s, %d/%d", q.Name, wrld.objective(q), have, need))
+			continue
+		}
+		lines = append(lines, wrld.finishQuest(userID, q)...)
+		lines = append(lines, "")
+	}
+	if !pending {
+		if q, ok := wrld.nextQuest(userID, npcID); ok {
+			tmpUser := wrld.users[userID]
+			if tmpUser.quests == nil {
+				tmpUser.quests = make(map[string]int)
+			}
+			tmpUser.quests[q.ID] = 0
+			wrld.users[userID] = tmpUser
+			lines = append(lines, "New quest: "+q.Name, "", q.Text, "",
+				"Goal:   "+wrld.objective(q), "Reward: "+wrld.rewardText(q.Reward))
+		} else if len(lines) == 0 {
+			lines = append(lines, "I have nothing more for you.")
+		}
+	}
+
+	tmpUser := wrld.users[userID]
+	tmpUser.modal = loadModal(textModal(giver.name, lines))
+	tmpUser.activeModal = "talk"
+	wrld.users[userID] = tmpUser
+	return "", nil
+}
+
+func (wrld *world) rewardText(r questReward) string {
+	parts := make([]string, 0, len(r.Items)+1)
+	if r.XP > 0 {
+		parts = append(parts, fmt.Sprintf("%d xp", r.XP))
+	}
+	for _, item := range r.Items {
+		parts = append(parts, wrld.itemTypes[item].Name)
+	}
+	if len(parts) == 0 {
+		return "thanks"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// finishQuest hands over what was fetched and pays out the reward. Items
+// that don't fit in the inventory are put down at the user's feet
+func (wrld *world) finishQuest(userID string, q questType) []string {
+	u := wrld.users[userID]
+	if q.Fetch != nil {
+		for i := 0; i < q.Fetch.Count; i++ {
+			u.takeItem(q.Fetch.Item)
+		}
+	}
+	delete(u.quests, q.ID)
+	if levels := u.gainXP(q.Reward.XP); levels > 0 {
+		log.Printf("user %s reached level %d", userID, u.level)
+	}
+	for _, item := range q.Reward.Items {
+		if len(u.inventory) < inventoryCapacity {
+			u.inventory = append(u.inventory, item)
+		} else if pos, ok := wrld.locations[0].positions[u.position.String()]; ok {
+			pos.items = append(pos.items, item)
+		}
+	}
+	wrld.users[userID] = u
+
+	p := wrld.profiles.get(userID)
+	if p.Quests == nil {
+		p.Quests = make(map[string]time.Time)
+	}
+	p.Quests[q.ID] = time.Now()
+	return []string{"Quest complete: " + q.Name, "Reward: " + wrld.rewardText(q.Reward)}
+}
+
+// advanceQuests counts kills and places reached toward the player's quests
+// under way. It is called by emit
+func (wrld *world) advanceQuests(ev gameEvent) {
+	u := wrld.users[ev.userID]
+	for _, id := range u.activeQuests() {
+		q := wrld.questTypes[id]
+		switch {
+		case ev.kind == eventKill && q.Kill != nil:
+			victim := wrld.users[ev.other]
+			if !victim.isNPC || victim.kind != q.Kill.Monster || u.quests[id] >= q.Kill.Count {
+				continue
+			}
+		case ev.kind == eventExplore && q.Reach != nil:
+			if u.quests[id] > 0 || !q.Reach.contains(u.position) {
+				continue
+			}
+		default:
+			continue
+		}
+		u.quests[id]++
+		if have, need := wrld.questProgress(ev.userID, q); have < need {
+			wrld.notify(ev.userID, fmt.Sprintf("%s: %d/%d", q.Name, have, need))
+		} else {
+			wrld.notify(ev.userID, fmt.Sprintf("%s done, return to %s", q.Name, wrld.users[q.Giver].name))
+		}
+	}
+}
+
+// questsCommand handles `quests`: a modal of the quests under way
+func (wrld *world) questsCommand(userID string) (string, error) {
+	u := wrld.users[userID]
+	lines := make([]string, 0)
+	for _, id := range u.activeQuests() {
+		q := wrld.questTypes[id]
+		have, need := wrld.questProgress(userID, q)
+		lines = append(lines, q.Name, fmt.Sprintf("  %s %d/%d", wrld.objective(q), have, need),
+			"  from "+wrld.users[q.Giver].name)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "none under way, talk to townsfolk")
+	}
+	done := 0
+	if p, ok := wrld.profiles.profiles[userID]; ok {
+		done = len(p.Quests)
+	}
+	lines = append(lines, "", fmt.Sprintf("Finished: %d of %d", done, len(wrld.questTypes)))
+
+	u.modal = loadModal(textModal("Quests", lines))
+	u.activeModal = "quests"
+	wrld.users[userID] = u
+	return "", nil
+}
+
+// textModal frames a title and lines of text, wrapping the long ones
+func textModal(title string, lines []string) string {
+	width := textModalWidth + 2
+	var b strings.Builder
+	b.WriteString("\n┌" + strings.Repeat("─", width) + "┐\n")
+	fmt.Fprintf(&b, "│ %-*.*s │▒\n", textModalWidth, textModalWidth, title)
+	b.WriteString("╞" + strings.Repeat("═", width) + "╡▒\n")
+	for _, line := range lines {
+		for _, row := range wrapText(line, textModalWidth) {
+			fmt.Fprintf(&b, "│ %-*s │▒\n", textModalWidth, row)
+		}
+	}
+	b.WriteString("└" + strings.Repeat("─", width) + "┘▒\n")
+	b.WriteString(" " + strings.Repeat("▒", width+1) + "\n")
+	return b.String()
+}
+
+// wrapText breaks s into rows of at most width runes at spaces, cutting
+// words too long for a row of their own. Every row keeps the indent s
+// starts with
+func wrapText(s string, width int) []string {
+	indent := s[:len(s)-len(strings.TrimLeft(s, " "))]
+	width -= len(indent)
+	rows := make([]string, 0)
+	row := ""
+	for _, word := range strings.Fields(s) {
+		for len([]rune(word)) > width {
+			if row != "" {
+				rows = append(rows, row)
+				row = ""
+			}
+			rows = append(rows, string([]rune(word)[:width]))
+			word = string([]rune(word)[width:])
+		}
+		switch {
+		case row == "":
+			row = word
+		case len([]rune(row))+1+len([]rune(word)) <= width:
+			row += " " + word
+		default:
+			rows = append(rows, row)
+			row = word
+		}
+	}
+	rows = append(rows, row)
+	for i := range rows {
+		rows[i] = indent + rows[i]
+	}
+	return rows
+}
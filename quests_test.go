@@ -0,0 +1,131 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestQuestGiver(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_2.map", 0, 2)
+	w.createUser("testingUser", 80, 20, position{x: 10, y: 8}, false)
+	w.createMonster("goblin", position{x: 11, y: 8}, w.monsterTypes["goblin"])
+	w.Lock()
+	defer w.Unlock()
+
+	if _, err := w.talk("testingUser", nil); err == nil {
+		t.Error("expected nobody to talk to away from the elder")
+	}
+	if w.damageUser("testingUser", "elder", 5) || w.users["elder"].life != 1 {
+		t.Error("expected townsfolk not to be hurt")
+	}
+
+	w.teleport("testingUser", position{x: 4, y: 3})
+	if _, err := w.talk("testingUser", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := w.users["testingUser"].quests["goblin-cull"]; !ok {
+		t.Fatal("expected the elder to hand out the first quest")
+	}
+	if modal := modalText(w.users["testingUser"].modal); !strings.Contains(modal, "Goblin Cull") {
+		t.Errorf("expected the quest in the modal:\n%s", modal)
+	}
+
+	w.teleport("testingUser", position{x: 10, y: 8})
+	for i := 0; i < 5; i++ {
+		// it respawns in the camp, out of harm's way
+		w.teleport("goblin", position{x: 11, y: 8})
+		w.damageUser("testingUser", "goblin", 100)
+	}
+	notices := w.users["testingUser"].notices
+	if !strings.Contains(notices[len(notices)-1].text, "return to the elder") {
+		t.Errorf("expected to be sent back to the elder, got %v", notices)
+	}
+
+	w.teleport("testingUser", position{x: 4, y: 3})
+	xp := w.users["testingUser"].xp
+	w.talk("testingUser", nil)
+	u := w.users["testingUser"]
+	if !w.finished("testingUser", "goblin-cull") || u.xp != xp+100 || u.inventory[len(u.inventory)-1] != "potion" {
+		t.Errorf("expected the quest rewarded, xp %d inventory %v", u.xp, u.inventory)
+	}
+	if _, ok := u.quests["lost-hall"]; !ok {
+		t.Fatal("expected the next quest handed out")
+	}
+
+	w.teleport("testingUser", position{x: 90, y: 22})
+	w.emit(gameEvent{kind: eventExplore, userID: "testingUser"})
+	w.questsCommand("testingUser")
+	if modal := modalText(w.users["testingUser"].modal); !strings.Contains(modal, "reach the hall 1/1") {
+		t.Errorf("expected the hall reached in the quests modal:\n%s", modal)
+	}
+}
+
+func TestFetchQuest(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_2.map", 0, 1)
+	w.createUser("testingUser", 80, 20, position{x: 4, y: 3}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	w.finishQuest("testingUser", w.questTypes["goblin-cull"])
+	w.finishQuest("testingUser", w.questTypes["lost-hall"])
+	tmpUser := w.users["testingUser"]
+	tmpUser.inventory = nil
+	w.users["testingUser"] = tmpUser
+
+	w.talk("testingUser", nil)
+	if _, ok := w.users["testingUser"].quests["bone-collector"]; !ok {
+		t.Fatal("expected the fetch quest once the ones before it are finished")
+	}
+	tmpUser = w.users["testingUser"]
+	tmpUser.inventory = []string{"bone", "bone"}
+	w.users["testingUser"] = tmpUser
+	w.talk("testingUser", nil)
+	if w.finished("testingUser", "bone-collector") {
+		t.Fatal("expected two bones not to be enough")
+	}
+
+	tmpUser = w.users["testingUser"]
+	tmpUser.inventory = append(tmpUser.inventory, "bone", "bone")
+	w.users["testingUser"] = tmpUser
+	w.talk("testingUser", nil)
+	inventory := strings.Join(w.users["testingUser"].inventory, ",")
+	if !w.finished("testingUser", "bone-collector") || inventory != "bone,leather" {
+		t.Errorf("expected three bones handed over for leather, have %s", inventory)
+	}
+}
+
+func TestValidateGivers(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	if err := genWorld("maps/map_1.map", 0, 1).validateGivers(); err != nil {
+		t.Errorf("expected a map without quest givers to pass, got %v", err)
+	}
+	w := genWorld("maps/map_2.map", 0, 1)
+	if err := w.validateGivers(); err != nil {
+		t.Fatal(err)
+	}
+	q := w.questTypes["goblin-cull"]
+	q.Giver = "merchant"
+	w.questTypes["goblin-cull"] = q
+	if err := w.validateGivers(); err == nil {
+		t.Error("expected a giver who hands out no quests to be caught")
+	}
+	q.Giver = "eldr"
+	w.questTypes["goblin-cull"] = q
+	if err := w.validateGivers(); err == nil {
+		t.Error("expected a misspelt giver to be caught")
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	rows := wrapText("  the quick brown fox jumps", 12)
+	if strings.Join(rows, "|") != "  the quick|  brown fox|  jumps" {
+		t.Errorf("unexpected rows %q", rows)
+	}
+	if rows := wrapText("", 10); len(rows) != 1 || rows[0] != "" {
+		t.Errorf("expected a blank line kept, got %q", rows)
+	}
+}
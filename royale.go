@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	// how often the storm outside the safe region hurts
+	stormTickEvery = time.Second
+	// time before the safe region starts to shrink
+	stormGrace = time.Second * 20
+	edgeGlyph  = '░'
+)
+
+// battleRoyale drops everyone at random, shrinks a safe region towards a
+// random point and hurts whoever is caught outside it. There is no respawn:
+// the fallen watch until the last one standing wins
+type battleRoyale struct {
+	minPlayers int
+	shrink     time.Duration
+	finalSize  int
+	damage     int
+
+	start     time.Time
+	full      rect
+	final     rect
+	zone      rect
+	nextStorm time.Time
+	alive     map[string]bool
+	entrants  int
+	// the fallen, first out first
+	fallen []string
+	kills  map[string]int
+}
+
+func newBattleRoyale(cfg modeConfig) *battleRoyale {
+	b := &battleRoyale{
+		minPlayers: cfg.MinPlayers,
+		shrink:     time.Second * time.Duration(cfg.ShrinkSeconds),
+		finalSize:  cfg.FinalSize,
+		damage:     cfg.StormDamage,
+		alive:      make(map[string]bool),
+		kills:      make(map[string]int),
+	}
+	if b.minPlayers <= 0 {
+		b.minPlayers = 2
+	}
+	if b.shrink <= 0 {
+		b.shrink = time.Minute * 3
+	}
+	if b.finalSize <= 0 {
+		b.finalSize = 8
+	}
+	if b.damage <= 0 {
+		b.damage = 1
+	}
+	return b
+}
+
+func (b *battleRoyale) Name() string { return "battle royale" }
+
+func (b *battleRoyale) Goal() string {
+	return "be the last one standing, stay inside the safe region"
+}
+
+func (b *battleRoyale) PvP() bool { return true }
+
+func (b *battleRoyale) Ready(wrld *world) (bool, string) {
+	n := len(wrld.players())
+	return n >= b.minPlayers, fmt.Sprintf("waiting for players %d/%d", n, b.minPlayers)
+}
+
+// dropCell picks a random open cell away from spawns and safe zones
+func dropCell(loc *location) position {
+	cells := make([]string, 0)
+	for _, z := range loc.zones {
+		cells = append(cells, z.cells...)
+	}
+	if len(cells) == 0 {
+		return loc.randomSpawn()
+	}
+	pos := loc.positions[cells[rand.Intn(len(cells))]]
+	return position{x: pos.x, y: pos.y}
+}
+
+// Start drops every player somewhere at random and picks where the safe
+// region ends up
+func (b *battleRoyale) Start(wrld *world, now time.Time) {
+	loc := &wrld.locations[0]
+	b.start = now
+	b.nextStorm = now.Add(stormTickEvery)
+	b.alive = make(map[string]bool)
+	b.fallen = nil
+	b.kills = make(map[string]int)
+
+	minX, minY, maxX, maxY := loc.bounds()
+	b.full = rect{X1: minX, Y1: minY, X2: maxX, Y2: maxY}
+	center := dropCell(loc)
+	half := b.finalSize / 2
+	b.final = rect{
+		X1: clamp(center.x-half, minX, maxX), Y1: clamp(center.y-half, minY, maxY),
+		X2: clamp(center.x+half, minX, maxX), Y2: clamp(center.y+half, minY, maxY),
+	}
+	b.zone = b.full
+
+	for _, id := range wrld.players() {
+		tmpUser := wrld.users[id]
+		tmpUser.out = false
+		tmpUser.life = tmpUser.maxLife
+		tmpUser.clearEffects()
+		wrld.users[id] = tmpUser
+		wrld.teleport(id, dropCell(loc))
+		b.alive[id] = true
+	}
+	b.entrants = len(b.alive)
+}
+
+// Join leaves players arriving mid-match watching until the next one
+func (b *battleRoyale) Join(wrld *world, userID string) {
+	if b.alive[userID] {
+		return
+	}
+	tmpUser := wrld.users[userID]
+	tmpUser.out = true
+	wrld.users[userID] = tmpUser
+	wrld.notify(userID, "a match is under way, you drop in next round")
+}
+
+// Death takes the victim out of the match where they fell instead of
+// respawning them
+func (b *battleRoyale) Death(wrld *world, attackerID, victimID string, where position) {
+	if !b.alive[victimID] {
+		return
+	}
+	delete(b.alive, victimID)
+	b.fallen = append(b.fallen, victimID)
+	if b.alive[attackerID] {
+		b.kills[attackerID]++
+	}
+
+	tmpUser := wrld.users[victimID]
+	tmpUser.position = where
+	tmpUser.out = true
+	wrld.users[victimID] = tmpUser
+	wrld.announce(fmt.Sprintf("%s is out, %d left", victimID, b.remaining(wrld)))
+}
+
+// remaining counts the players still in the match
+func (b *battleRoyale) remaining(wrld *world) int {
+	n := 0
+	for id := range b.alive {
+		if _, ok := wrld.users[id]; ok {
+			n++
+		}
+	}
+	return n
+}
+
+// zoneAt is the safe region at a moment, shrinking evenly from the whole
+// location to the final region once the grace period is over
+func (b *battleRoyale) zoneAt(now time.Time) rect {
+	progress := float64(now.Sub(b.start)-stormGrace) / float64(b.shrink)
+	if progress <= 0 {
+		return b.full
+	}
+	if progress > 1 {
+		progress = 1
+	}
+	lerp := func(from, to int) int {
+		return from + int(float64(to-from)*progress)
+	}
+	return rect{
+		X1: lerp(b.full.X1, b.final.X1), Y1: lerp(b.full.Y1, b.final.Y1),
+		X2: lerp(b.full.X2, b.final.X2), Y2: lerp(b.full.Y2, b.final.Y2),
+	}
+}
+
+// Tick shrinks the safe region and lets the storm hurt those outside it
+func (b *battleRoyale) Tick(wrld *world, now time.Time) {
+	shrunk := b.zone != b.zoneAt(now)
+	b.zone = b.zoneAt(now)
+	if shrunk && b.zone == b.final {
+		wrld.announce("the safe region has stopped shrinking")
+	}
+	if now.Before(b.nextStorm) {
+		return
+	}
+	b.nextStorm = now.Add(stormTickEvery)
+
+	for _, id := range wrld.players() {
+		u := wrld.users[id]
+		if !b.alive[id] || b.zone.contains(u.position) {
+			continue
+		}
+		// the storm goes around armor and safe zones alike
+		u.life -= b.damage
+		wrld.users[id] = u
+		if u.life <= 0 {
+			wrld.damageUser("", id, 0)
+		}
+	}
+}
+
+func (b *battleRoyale) Over(wrld *world, now time.Time) bool {
+	left := b.remaining(wrld)
+	return left == 0 || (left == 1 && b.entrants > 1)
+}
+
+func (b *battleRoyale) Results(wrld *world) (string, []string) {
+	winner := "nobody"
+	standing := make([]string, 0)
+	for _, id := range wrld.players() {
+		if b.alive[id] {
+			standing = append(standing, id)
+		}
+	}
+	if len(standing) == 1 {
+		winner = standing[0]
+	}
+	for i := len(b.fallen) - 1; i >= 0; i-- {
+		standing = append(standing, b.fallen[i])
+	}
+
+	lines := make([]string, 0, len(standing))
+	for i, id := range standing {
+		lines = append(lines, fmt.Sprintf("%2d. %-16s %2d kills", i+1, id, b.kills[id]))
+	}
+	return winner, lines
+}
+
+func (b *battleRoyale) Status(wrld *world, userID string) string {
+	status := fmt.Sprintf("alive %d", b.remaining(wrld))
+	if wrld.users[userID].out {
+		status += " watching"
+	} else if !b.zone.contains(wrld.users[userID].position) {
+		status += " in the storm"
+	}
+	return status
+}
+
+// Glyph draws the edge of the safe region, and tints what is outside it
+func (b *battleRoyale) Glyph(wrld *world, cell string) (rune, string, bool) {
+	pos, ok := wrld.locations[0].positions[cell]
+	if !ok || len(pos.items) > 0 {
+		return 0, styleNone, false
+	}
+	p := position{x: pos.x, y: pos.y}
+	if !b.zone.contains(p) {
+		return pos.glyph(), styleStorm, true
+	}
+	onEdge := p.x == b.zone.X1 || p.x == b.zone.X2 || p.y == b.zone.Y1 || p.y == b.zone.Y2
+	if !onEdge || b.zone == b.full {
+		return 0, styleNone, false
+	}
+	if pos.closed || pos.glyph() != ' ' {
+		return pos.glyph(), styleStormEdge, true
+	}
+	return edgeGlyph, styleStormEdge, true
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
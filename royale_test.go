@@ -0,0 +1,117 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBattleRoyale(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 3)
+	w.createUser("Alice", 80, 20, position{x: 2, y: 3}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	mode, err := w.newGameMode("royale", modeConfig{ShrinkSeconds: 60, FinalSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.setMode(mode, time.Minute*10)
+	now := time.Now()
+	w.tickMode(now)
+	if w.round.running {
+		t.Fatal("expected the lobby to wait for a second player")
+	}
+	if got := w.modeStatus("Alice", now); got != "waiting for players 1/2" {
+		t.Errorf("unexpected lobby status %q", got)
+	}
+
+	w.createUser("Bob", 80, 20, position{x: 3, y: 3}, false)
+	w.tickMode(now)
+	if !w.round.running {
+		t.Fatal("expected the match to start with two players")
+	}
+	b := mode.(*battleRoyale)
+	for _, id := range []string{"Alice", "Bob"} {
+		tmpUser := w.users[id]
+		tmpUser.protectedUntil = time.Time{}
+		w.users[id] = tmpUser
+		if !b.alive[id] {
+			t.Errorf("expected %s dropped into the match", id)
+		}
+	}
+
+	// latecomers watch
+	w.createUser("Carol", 80, 20, position{x: 4, y: 3}, false)
+	if !w.users["Carol"].out {
+		t.Error("expected a player joining mid-match to watch")
+	}
+	if _, err := w.attack("Carol", nil); err == nil {
+		t.Error("expected someone watching not to attack")
+	}
+
+	where := w.users["Bob"].position
+	w.damageUser("Alice", "Bob", 100)
+	if !w.users["Bob"].out || w.users["Bob"].position.String() != where.String() {
+		t.Errorf("expected Bob out where they fell, at %s", w.users["Bob"].position)
+	}
+	if w.round.running && !b.Over(w, now) {
+		t.Error("expected the last one standing to end the match")
+	}
+	w.tickMode(now)
+	if !strings.Contains(w.round.results, "Winner: Alice") {
+		t.Errorf("expected Alice to win:\n%s", w.round.results)
+	}
+	if w.users["Bob"].out || w.users["Carol"].out {
+		t.Error("expected everyone back in play after the match")
+	}
+}
+
+func TestStorm(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("Alice", 80, 20, position{x: 2, y: 3}, false)
+	w.createUser("Bob", 80, 20, position{x: 3, y: 3}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	mode, err := w.newGameMode("royale", modeConfig{ShrinkSeconds: 60, FinalSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.setMode(mode, time.Minute*10)
+	now := time.Now()
+	w.tickMode(now)
+	b := mode.(*battleRoyale)
+
+	if got := b.zoneAt(now.Add(stormGrace)); got != b.full {
+		t.Errorf("expected the region whole during the grace period, got %+v", got)
+	}
+	if got := b.zoneAt(now.Add(stormGrace + time.Minute*2)); got != b.final {
+		t.Errorf("expected the region fully shrunk, got %+v want %+v", got, b.final)
+	}
+
+	later := now.Add(stormGrace + time.Minute)
+	w.tickMode(later)
+	// put Alice outside the final region
+	outside := position{x: b.final.X1 - 1, y: b.final.Y1 - 1}
+	if b.final.X1 <= b.full.X1+1 {
+		outside = position{x: b.final.X2 + 1, y: b.final.Y2 + 1}
+	}
+	w.teleport("Alice", outside)
+	if b.zone.contains(w.users["Alice"].position) {
+		t.Skip("no free cell outside the region near its corner")
+	}
+	life := w.users["Alice"].life
+	w.tickMode(later.Add(stormTickEvery))
+	if got := w.users["Alice"].life; got != life-1 {
+		t.Errorf("expected the storm to hurt, life %d", got)
+	}
+
+	if r, style, ok := mode.Glyph(w, w.users["Alice"].position.String()); !ok || style != styleStorm {
+		t.Errorf("expected the storm drawn outside the region, got %q %q", r, style)
+	}
+}
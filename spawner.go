@@ -0,0 +1,200 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRespawnDelay = time.Second * 30
+	// monsters never appear within this many cells of a player spawn point
+	spawnAreaRadius = 5
+	// random cells tried per spawn before waiting for the next tick
+	spawnAttempts = 20
+)
+
+// zone is a region of a location whose monster population is kept at a
+// target by the spawner
+type zone struct {
+	name       string
+	population int
+	respawn    time.Duration
+	spawnTable []spawnEntry
+	// open cells outside the player spawn area
+	cells []string
+	// when each missing monster is due to respawn
+	pending []time.Time
+}
+
+// metaZone is how a zone is declared in a map sidecar. A population of 0
+// means the world's monster saturation percentage of the zone's open cells
+type metaZone struct {
+	Name           string       `json:"name"`
+	X1             int          `json:"x1"`
+	Y1             int          `json:"y1"`
+	X2             int          `json:"x2"`
+	Y2             int          `json:"y2"`
+	Population     int          `json:"population"`
+	RespawnSeconds int          `json:"respawn_seconds"`
+	Monsters       []spawnEntry `json:"monsters"`
+}
+
+// inSpawnArea reports whether p is near enough a player spawn point that
+// monsters should not appear there
+func (loc *location) inSpawnArea(p position) bool {
+	for _, spawn := range loc.spawns {
+		if abs(p.x-spawn.x) <= spawnAreaRadius && abs(p.y-spawn.y) <= spawnAreaRadius {
+			return true
+		}
+	}
+	return false
+}
+
+// newZones sets up the location's zones, defaulting to one zone covering
+// the whole location
+func (loc *location) newZones(defs []metaZone, monsterSaturationPercent int) []*zone {
+	if len(defs) == 0 {
+		minX, minY, maxX, maxY := loc.bounds()
+		defs = []metaZone{{Name: "all", X1: minX, Y1: minY, X2: maxX, Y2: maxY}}
+	}
+
+	zones := make([]*zone, 0, len(defs))
+	for _, def := range defs {
+		z := &zone{
+			name:       def.Name,
+			population: def.Population,
+			respawn:    time.Duration(def.RespawnSeconds) * time.Second,
+			spawnTable: def.Monsters,
+		}
+		if z.respawn == 0 {
+			z.respawn = defaultRespawnDelay
+		}
+		if len(z.spawnTable) == 0 {
+			z.spawnTable = loc.spawnTable
+		}
+		for _, pos := range loc.positions {
+			if pos.x < def.X1 || pos.x > def.X2 || pos.y < def.Y1 || pos.y > def.Y2 {
+				continue
+			}
+			if !pos.closed && !pos.hazardous() && !loc.inSpawnArea(*pos) && !loc.inSafeZone(*pos) {
+				z.cells = append(z.cells, pos.String())
+			}
+		}
+		if def.Population == 0 {
+			z.population = monsterSaturationPercent * len(z.cells) / 100
+		}
+		zones = append(zones, z)
+	}
+	return zones
+}
+
+// zonePopulation counts the living monsters belonging to each zone
+func (wrld *world) zonePopulation() map[string]int {
+	alive := make(map[string]int)
+	for _, u := range wrld.users {
+		if u.isNPC && u.deaths == 0 && u.zone != "" {
+			alive[u.zone]++
+		}
+	}
+	return alive
+}
+
+// watchedCells returns every cell some player can currently see
+func (wrld *world) watchedCells() map[string]bool {
+	watched := make(map[string]bool)
+	for _, u := range wrld.users {
+		if u.isNPC {
+			continue
+		}
+		for cell := range wrld.locations[0].fieldOfView(u.position, u.sightRadius) {
+			watched[cell] = true
+		}
+	}
+	return watched
+}
+
+// spawnInZone places one monster on a free cell of the zone that no player
+// is looking at
+func (wrld *world) spawnInZone(z *zone, watched map[string]bool) bool {
+	if len(z.cells) == 0 {
+		return false
+	}
+	loc := &wrld.locations[0]
+
+	occupied := make(map[string]bool)
+	for _, u := range wrld.users {
+		occupied[u.position.String()] = true
+	}
+
+	for i := 0; i < spawnAttempts; i++ {
+		cell := z.cells[rand.Intn(len(z.cells))]
+		pos := loc.positions[cell]
+		if pos.closed || occupied[cell] || watched[cell] {
+			continue
+		}
+
+		kind, ok := wrld.pickMonsterType(z.spawnTable)
+		if !ok {
+			return false
+		}
+		mID := strconv.Itoa(rand.Intn(2000000000))
+		if !wrld.createMonster(mID, position{x: pos.x, y: pos.y}, kind) {
+			return false
+		}
+		tmpUser := wrld.users[mID]
+		tmpUser.zone = z.name
+		wrld.users[mID] = tmpUser
+		wrld.startMonster(mID)
+		return true
+	}
+	return false
+}
+
+// fillZones brings every zone up to its population straight away, used
+// when the world is created
+func (wrld *world) fillZones() int {
+	created := 0
+	alive := wrld.zonePopulation()
+	watched := wrld.watchedCells()
+	for _, z := range wrld.locations[0].zones {
+		for i := alive[z.name]; i < z.population; i++ {
+			if wrld.spawnInZone(z, watched) {
+				created++
+			}
+		}
+	}
+	return created
+}
+
+// populate schedules respawns for monsters that have died and spawns the
+// ones that are due. It is called from the game loop with the world locked
+func (wrld *world) populate(now time.Time) {
+	alive := wrld.zonePopulation()
+	var watched map[string]bool
+
+	for _, z := range wrld.locations[0].zones {
+		for missing := z.population - alive[z.name] - len(z.pending); missing > 0; missing-- {
+			z.pending = append(z.pending, now.Add(z.respawn))
+		}
+
+		due := z.pending[:0]
+		for _, at := range z.pending {
+			if now.Before(at) {
+				due = append(due, at)
+				continue
+			}
+			if watched == nil {
+				watched = wrld.watchedCells()
+			}
+			if !wrld.spawnInZone(z, watched) {
+				// nowhere to put it yet, try again next tick
+				due = append(due, at)
+				continue
+			}
+			log.Printf("respawned monster in zone %s", z.name)
+		}
+		z.pending = due
+	}
+}
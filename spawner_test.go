@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestSpawnAreaExcluded(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 100, 1000)
+	// the spawned monsters are already roaming
+	w.Lock()
+	defer w.Unlock()
+
+	for _, u := range w.users {
+		if w.locations[0].inSpawnArea(u.position) {
+			t.Errorf("monster spawned in the player spawn area at %s", u.position)
+		}
+	}
+	if len(w.users) == 0 {
+		t.Error("expected monsters to spawn")
+	}
+}
+
+func TestPopulate(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 100)
+	// the monsters populate spawns are set going
+	w.Lock()
+	defer w.Unlock()
+	z := w.locations[0].zones[0]
+	z.population = 1
+	z.respawn = time.Minute
+
+	now := time.Now()
+	w.populate(now)
+	if got := w.zonePopulation()[z.name]; got != 0 {
+		t.Fatalf("expected the first monster to wait for the respawn delay, got %d", got)
+	}
+
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+	watched := w.watchedCells()
+
+	w.populate(now.Add(time.Minute))
+	if got := w.zonePopulation()[z.name]; got != 1 {
+		t.Fatalf("expected a monster once the delay passed, got %d", got)
+	}
+	for id, u := range w.users {
+		if u.isNPC && watched[u.position.String()] {
+			t.Errorf("monster %s spawned in view of a player at %s", id, u.position)
+		}
+	}
+
+	// a dead monster is replaced after the delay, not straight away
+	for id, u := range w.users {
+		if u.isNPC {
+			u.deaths++
+			w.users[id] = u
+		}
+	}
+	w.populate(now.Add(time.Minute))
+	if got := w.zonePopulation()[z.name]; got != 0 {
+		t.Errorf("expected the respawn to wait, got %d alive", got)
+	}
+	w.populate(now.Add(2 * time.Minute))
+	if got := w.zonePopulation()[z.name]; got != 1 {
+		t.Errorf("expected the monster to respawn, got %d alive", got)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	// monsters spawned by survival waves belong to this zone, so the
+	// spawner leaves them out of its own counts
+	waveZone = "wave"
+	// time to get ready before the first wave
+	waveGrace = time.Second * 10
+)
+
+// survival has the players hold out together against waves of monsters,
+// each bigger than the last. They win by clearing the final wave and lose
+// when they have died too many times between them
+type survival struct {
+	waves      int
+	firstWave  int
+	growth     int
+	every      time.Duration
+	deathLimit int
+	spawnTable []spawnEntry
+
+	wave     int
+	nextWave time.Time
+	deaths   int
+	kills    map[string]int
+}
+
+func newSurvival(cfg modeConfig) *survival {
+	s := &survival{
+		waves:      cfg.Waves,
+		firstWave:  cfg.FirstWave,
+		growth:     cfg.WaveGrowth,
+		every:      time.Second * time.Duration(cfg.WaveSeconds),
+		deathLimit: cfg.DeathLimit,
+		spawnTable: cfg.Monsters,
+		kills:      make(map[string]int),
+	}
+	if s.waves <= 0 {
+		s.waves = 5
+	}
+	if s.firstWave <= 0 {
+		s.firstWave = 3
+	}
+	if s.growth <= 0 {
+		s.growth = 2
+	}
+	if s.every <= 0 {
+		s.every = time.Second * 45
+	}
+	if s.deathLimit <= 0 {
+		s.deathLimit = 5
+	}
+	return s
+}
+
+func (s *survival) Name() string { return "survival" }
+
+func (s *survival) Goal() string {
+	return fmt.Sprintf("survive %d waves, together you can die %d times", s.waves, s.deathLimit)
+}
+
+func (s *survival) PvP() bool { return false }
+
+// Start clears out what is left of the previous round's waves
+func (s *survival) Start(wrld *world, now time.Time) {
+	s.wave = 0
+	s.deaths = 0
+	s.kills = make(map[string]int)
+	s.nextWave = now.Add(waveGrace)
+	for id, u := range wrld.users {
+		if u.isNPC && u.zone == waveZone && u.deaths == 0 {
+			// the monster's own goroutine removes it once it has died
+			u.deaths++
+			wrld.users[id] = u
+		}
+	}
+}
+
+func (s *survival) Join(wrld *world, userID string) {}
+
+func (s *survival) Death(wrld *world, attackerID, victimID string, where position) {
+	victim := wrld.users[victimID]
+	if !victim.isNPC {
+		s.deaths++
+		return
+	}
+	if attacker, ok := wrld.users[attackerID]; ok && !attacker.isNPC && victim.zone == waveZone {
+		s.kills[attackerID]++
+	}
+}
+
+// remaining counts the living monsters of the waves
+func (s *survival) remaining(wrld *world) int {
+	return wrld.zonePopulation()[waveZone]
+}
+
+// Tick sends the next wave when it is due, or early once the last one has
+// been cleared
+func (s *survival) Tick(wrld *world, now time.Time) {
+	if s.wave >= s.waves {
+		return
+	}
+	if now.Before(s.nextWave) && (s.wave == 0 || s.remaining(wrld) > 0) {
+		return
+	}
+	s.wave++
+	s.nextWave = now.Add(s.every)
+
+	zones := wrld.locations[0].zones
+	if len(zones) == 0 {
+		return
+	}
+	watched := wrld.watchedCells()
+	size := s.firstWave + (s.wave-1)*s.growth
+	spawned := 0
+	for i := 0; i < size; i++ {
+		z := *zones[rand.Intn(len(zones))]
+		z.name = waveZone
+		z.spawnTable = s.spawnTable
+		if wrld.spawnInZone(&z, watched) {
+			spawned++
+		}
+	}
+	wrld.announce(fmt.Sprintf("wave %d of %d: %d monsters", s.wave, s.waves, spawned))
+}
+
+func (s *survival) Over(wrld *world, now time.Time) bool {
+	return s.deaths >= s.deathLimit || s.cleared(wrld)
+}
+
+func (s *survival) cleared(wrld *world) bool {
+	return s.wave >= s.waves && s.remaining(wrld) == 0
+}
+
+func (s *survival) Results(wrld *world) (string, []string) {
+	winner := "the monsters"
+	if s.cleared(wrld) && s.deaths < s.deathLimit {
+		winner = "the survivors"
+	}
+	lines := []string{
+		fmt.Sprintf("wave %d of %d", s.wave, s.waves),
+		fmt.Sprintf("deaths %d of %d", s.deaths, s.deathLimit),
+		"",
+	}
+	_, standings := rank(s.kills)
+	for _, st := range standings {
+		lines = append(lines, fmt.Sprintf("%-20s %5d", st.name, st.score))
+	}
+	return winner, lines
+}
+
+func (s *survival) Status(wrld *world, userID string) string {
+	return fmt.Sprintf("wave %d/%d left %d deaths %d/%d", s.wave, s.waves, s.remaining(wrld), s.deaths, s.deathLimit)
+}
+
+func (s *survival) Glyph(wrld *world, cell string) (rune, string, bool) {
+	return 0, styleNone, false
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSurvivalWaves(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 10)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	mode, err := w.newGameMode("survival", modeConfig{Waves: 2, FirstWave: 1, WaveGrowth: 1, WaveSeconds: 30,
+		Monsters: []spawnEntry{{Type: "goblin", Weight: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.setMode(mode, time.Minute*10)
+	now := time.Now()
+	w.tickMode(now)
+	s := mode.(*survival)
+	if s.wave != 0 {
+		t.Fatal("expected a grace period before the first wave")
+	}
+
+	w.tickMode(now.Add(waveGrace))
+	if s.wave != 1 || s.remaining(w) != 1 {
+		t.Fatalf("expected the first wave of one, wave %d with %d left", s.wave, s.remaining(w))
+	}
+	tmpUser := w.users["testingUser"]
+	tmpUser.protectedUntil = time.Time{}
+	w.users["testingUser"] = tmpUser
+
+	// clearing a wave brings the next one early and bigger
+	for id, u := range w.users {
+		if u.zone == waveZone {
+			w.damageUser("testingUser", id, 100)
+		}
+	}
+	w.tickMode(now.Add(waveGrace + time.Second))
+	if s.wave != 2 || s.remaining(w) != 2 {
+		t.Fatalf("expected the second wave of two, wave %d with %d left", s.wave, s.remaining(w))
+	}
+	for id, u := range w.users {
+		if u.zone == waveZone && u.deaths == 0 {
+			w.damageUser("testingUser", id, 100)
+		}
+	}
+	w.tickMode(now.Add(waveGrace + time.Second*2))
+	if w.round.running {
+		t.Fatal("expected the round won once the last wave is cleared")
+	}
+	if !strings.Contains(w.round.results, "the survivors") || s.kills["testingUser"] != 3 {
+		t.Errorf("expected the survivors to win with 3 kills, got %d:\n%s", s.kills["testingUser"], w.round.results)
+	}
+}
+
+func TestSurvivalDeathLimit(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 1)
+	w.createUser("testingUser", 80, 20, position{x: 2, y: 3}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	mode, err := w.newGameMode("survival", modeConfig{DeathLimit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.setMode(mode, time.Minute)
+	now := time.Now()
+	w.tickMode(now)
+	if mode.PvP() {
+		t.Error("expected survivors not to fight each other")
+	}
+
+	tmpUser := w.users["testingUser"]
+	tmpUser.life = 0
+	w.users["testingUser"] = tmpUser
+	w.damageUser("", "testingUser", 0)
+	w.tickMode(now)
+	if w.round.running {
+		t.Fatal("expected the round lost at the death limit")
+	}
+	if !strings.Contains(w.round.results, "the monsters") {
+		t.Errorf("expected the monsters to win:\n%s", w.round.results)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// how often standing on lava hurts or on a spring heals
+const terrainTickEvery = time.Second
+
+// tileType is a kind of terrain, declared in a map sidecar's legend under
+// the character that stands for it in the map file. Characters missing from
+// the legend are floor when they are a space and wall otherwise
+type tileType struct {
+	Name     string `json:"name"`
+	Passable bool   `json:"passable"`
+	Opaque   bool   `json:"opaque"`
+	// energy it costs to step onto; 0 means the usual 1
+	MoveCost int `json:"move_cost"`
+	// life lost or gained every terrain tick by whoever stands on it
+	Damage int `json:"damage"`
+	Heal   int `json:"heal"`
+	// status effect applied to whoever steps onto it, see effects.go
+	Effect *effectSpec `json:"effect"`
+	// the legend character a door turns into with `open`
+	Toggle string `json:"toggle"`
+	// the kind of workstation it is, for recipes crafted there, see
+	// crafting.go
+	Workstation string `json:"workstation"`
+	// hidden tiles look like the Looks character until stepped on
+	Hidden bool   `json:"hidden"`
+	Looks  string `json:"looks"`
+	// optional SGR parameters used in color mode
+	Style string `json:"style"`
+}
+
+// applyLegend turns the cells whose character is in the legend into tiles
+func (loc *location) applyLegend(legend map[string]tileType) error {
+	loc.legend = make(map[rune]*tileType, len(legend))
+	for key, tile := range legend {
+		r := []rune(key)
+		if len(r) != 1 || runeWidth(r[0]) != 1 {
+			return fmt.Errorf("legend key %q is not one narrow character", key)
+		}
+		if tile.Looks != "" && stringWidth(tile.Looks) != 1 {
+			return fmt.Errorf("tile %q looks like %q, which is not one narrow character", key, tile.Looks)
+		}
+		if tile.Effect != nil {
+			if err := tile.Effect.validate(); err != nil {
+				return fmt.Errorf("tile %q: %v", key, err)
+			}
+		}
+		if tile.Name == "" {
+			tile.Name = key
+		}
+		t := tile
+		loc.legend[r[0]] = &t
+	}
+	for key, tile := range loc.legend {
+		if tile.Toggle == "" {
+			continue
+		}
+		if _, ok := loc.legend[[]rune(tile.Toggle)[0]]; !ok {
+			return fmt.Errorf("tile %q toggles to %q, which is not in the legend", string(key), tile.Toggle)
+		}
+	}
+
+	for _, pos := range loc.positions {
+		if tile, ok := loc.legend[pos.character]; ok {
+			pos.setTile(pos.character, tile)
+		}
+	}
+	return nil
+}
+
+func (pos *position) setTile(r rune, tile *tileType) {
+	pos.character = r
+	pos.tile = tile
+	pos.closed = !tile.Passable
+	pos.effect = tile.Effect
+}
+
+// glyph is what the cell's terrain looks like, hiding undiscovered traps
+func (pos *position) glyph() rune {
+	if pos.tile != nil && pos.tile.Hidden && !pos.revealed {
+		if pos.tile.Looks == "" {
+			return ' '
+		}
+		return []rune(pos.tile.Looks)[0]
+	}
+	return pos.character
+}
+
+// moveCost is the energy it takes to step onto the cell
+func (pos *position) moveCost() int {
+	if pos.tile == nil || pos.tile.MoveCost <= 0 {
+		return 1
+	}
+	return pos.tile.MoveCost
+}
+
+// hazardous reports whether monsters should keep off the cell
+func (pos *position) hazardous() bool {
+	return pos.tile != nil && (pos.tile.Damage > 0 || pos.tile.Hidden || pos.tile.Effect != nil)
+}
+
+// openDoor opens or closes the doors next to the user
+func (wrld *world) openDoor(userID string) (string, error) {
+	loc := &wrld.locations[0]
+	p := wrld.users[userID].position
+	toggled := 0
+	message := ""
+	for _, dir := range attackDirections {
+		pos, ok := loc.positions[fmt.Sprintf("%d,%d", p.x+dir.x, p.y+dir.y)]
+		if !ok || pos.tile == nil || pos.tile.Toggle == "" || pos.userID != "" {
+			continue
+		}
+		r := []rune(pos.tile.Toggle)[0]
+		pos.setTile(r, loc.legend[r])
+		toggled++
+		if pos.tile.Passable {
+			message = fmt.Sprintf("opened %s", pos.tile.Name)
+		} else {
+			message = fmt.Sprintf("closed %s", pos.tile.Name)
+		}
+	}
+	if toggled == 0 {
+		return "", fmt.Errorf("no door here")
+	}
+	return message, nil
+}
+
+// tickTerrain hurts those standing on lava and heals those at springs. It
+// is called from the game loop with the world locked
+func (wrld *world) tickTerrain(now time.Time) {
+	if now.Before(wrld.nextTerrainTick) {
+		return
+	}
+	wrld.nextTerrainTick = now.Add(terrainTickEvery)
+
+	for userID, u := range wrld.users {
+		pos, ok := wrld.locations[0].positions[u.position.String()]
+		if !ok || pos.tile == nil || pos.userID != userID {
+			continue
+		}
+		if pos.tile.Heal > 0 && u.life < u.maxLife {
+			u.life += pos.tile.Heal
+			if u.life > u.maxLife {
+				u.life = u.maxLife
+			}
+		}
+		if !wrld.sheltered(userID, now) {
+			u.life -= pos.tile.Damage
+		}
+		wrld.users[userID] = u
+		if u.life <= 0 {
+			// like poison, terrain goes around armor
+			wrld.damageUser("", userID, 0)
+		}
+	}
+}
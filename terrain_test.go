@@ -0,0 +1,138 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+)
+
+// step plays a single move through the game loop
+func step(w *world, userID, move string) {
+	result := make(chan commandStatus, 1)
+	w.Lock()
+	w.commands = append(w.commands, command{cmd: move, userID: userID, result: result})
+	w.Unlock()
+	w.updateBoard()
+}
+
+func TestLegendTiles(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_2.map", 0, 1)
+	loc := &w.locations[0]
+
+	if tile := loc.positions["9,8"].tile; tile == nil || tile.Name != "water" || loc.positions["9,8"].closed {
+		t.Error("expected passable water at (9,8)")
+	}
+	if !loc.positions["18,4"].closed || !loc.isOpaque(18, 4) {
+		t.Error("expected a closed door to block movement and sight")
+	}
+	if loc.isOpaque(9, 8) {
+		t.Error("expected water to be see-through")
+	}
+	if got := loc.positions["15,2"].glyph(); got != ' ' {
+		t.Errorf("expected the trap to look like floor, got %q", got)
+	}
+}
+
+func TestOpenDoor(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_2.map", 0, 1)
+	w.createUser("testingUser", 80, 20, position{x: 17, y: 4}, false)
+	w.Lock()
+	defer w.Unlock()
+	door := w.locations[0].positions["18,4"]
+
+	if _, err := w.openDoor("testingUser"); err != nil {
+		t.Fatal(err)
+	}
+	if door.closed || door.character != '\'' || w.locations[0].isOpaque(18, 4) {
+		t.Error("expected the door to open")
+	}
+	if _, err := w.openDoor("testingUser"); err != nil {
+		t.Fatal(err)
+	}
+	if !door.closed || door.character != '+' {
+		t.Error("expected the door to close again")
+	}
+
+	tmpUser := w.users["testingUser"]
+	tmpUser.position = position{x: 2, y: 3}
+	w.users["testingUser"] = tmpUser
+	if _, err := w.openDoor("testingUser"); err == nil {
+		t.Error("expected no door next to the spawn")
+	}
+}
+
+func TestMoveCostAndTraps(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_2.map", 0, 1)
+	w.createUser("testingUser", 80, 20, position{x: 8, y: 8}, false)
+
+	// start full so energy regen doesn't muddy the cost
+	w.Lock()
+	tmpUser := w.users["testingUser"]
+	tmpUser.energy = tmpUser.maxEnergy
+	w.users["testingUser"] = tmpUser
+	w.Unlock()
+	energy := tmpUser.energy
+	step(w, "testingUser", "md")
+	if got := w.users["testingUser"].position.x; got != 9 {
+		t.Fatalf("expected to wade into the water, x %d", got)
+	}
+	if got := w.users["testingUser"].energy; got != energy-3 {
+		t.Errorf("expected water to cost 3 energy, went from %d to %d", energy, got)
+	}
+
+	w.Lock()
+	tmpUser = w.users["testingUser"]
+	tmpUser.position = position{x: 14, y: 2}
+	tmpUser.energy = tmpUser.maxEnergy
+	w.users["testingUser"] = tmpUser
+	w.Unlock()
+	step(w, "testingUser", "md")
+	if !w.users["testingUser"].hasEffect(effectStun, time.Now()) {
+		t.Error("expected the trap to stun")
+	}
+	if got := w.locations[0].positions["15,2"].glyph(); got != '^' {
+		t.Errorf("expected the sprung trap to show, got %q", got)
+	}
+}
+
+func TestLavaAndSprings(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_2.map", 0, 2)
+	w.createUser("burnt", 80, 20, position{x: 14, y: 11}, false)
+	w.createUser("healed", 80, 20, position{x: 12, y: 5}, false)
+	occupy(w, "burnt")
+	occupy(w, "healed")
+	fightable(w, "burnt")
+	w.Lock()
+	defer w.Unlock()
+
+	tmpUser := w.users["healed"]
+	tmpUser.life = 1
+	w.users["healed"] = tmpUser
+
+	now := time.Now()
+	w.tickTerrain(now)
+	if got := w.users["burnt"].life; got != w.users["burnt"].maxLife-1 {
+		t.Errorf("expected lava to burn, life %d", got)
+	}
+	if got := w.users["healed"].life; got != 2 {
+		t.Errorf("expected the spring to heal, life %d", got)
+	}
+
+	// nothing more until the next terrain tick
+	w.tickTerrain(now.Add(terrainTickEvery / 2))
+	if got := w.users["healed"].life; got != 2 {
+		t.Errorf("expected to wait for the next tick, life %d", got)
+	}
+
+	for i := 1; i <= w.users["burnt"].maxLife; i++ {
+		w.tickTerrain(now.Add(time.Duration(i) * terrainTickEvery))
+	}
+	if got := w.users["burnt"].deaths; got != 1 {
+		t.Errorf("expected lava to kill, deaths %d", got)
+	}
+}
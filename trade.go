@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// players trade within this many cells of each other
+const tradeReach = 3
+
+// trade is a window two players put items and gold into. It goes through
+// once both have confirmed what is on the table; any change to an offer
+// takes the confirmations back
+type trade struct {
+	parties   [2]string
+	offers    map[string]*tradeOffer
+	confirmed map[string]bool
+}
+
+type tradeOffer struct {
+	items []string
+	gold  int
+}
+
+// other is the party trading with userID
+func (t *trade) other(userID string) string {
+	if t.parties[0] == userID {
+		return t.parties[1]
+	}
+	return t.parties[0]
+}
+
+// inReach reports whether two users are close enough to trade
+func (wrld *world) inReach(a, b string) bool {
+	ua, okA := wrld.users[a]
+	ub, okB := wrld.users[b]
+	return okA && okB && abs(ua.position.x-ub.position.x) <= tradeReach && abs(ua.position.y-ub.position.y) <= tradeReach
+}
+
+// tradeCommand handles `trade`: ask a player to trade or accept their
+// request, offer items or gold, confirm and cancel. Every change happens
+// within the command, under the world lock, so nothing offered can be
+// spent twice
+func (wrld *world) tradeCommand(userID string, args []string) (string, error) {
+	t := wrld.trades[userID]
+	if len(args) == 0 {
+		if t == nil {
+			return "not trading", nil
+		}
+		wrld.showTrade(t)
+		return "", nil
+	}
+
+	switch args[0] {
+	case "offer":
+		if t == nil {
+			return "", fmt.Errorf("not trading")
+		}
+		if err := wrld.offer(userID, t, args[1:]); err != nil {
+			return "", err
+		}
+		t.confirmed = make(map[string]bool)
+		wrld.notify(t.other(userID), fmt.Sprintf("%s changed their offer", userID))
+		wrld.showTrade(t)
+		return "", nil
+
+	case "confirm":
+		if t == nil {
+			return "", fmt.Errorf("not trading")
+		}
+		t.confirmed[userID] = true
+		if !t.confirmed[t.other(userID)] {
+			wrld.notify(t.other(userID), fmt.Sprintf("%s confirmed, :trade confirm to accept", userID))
+			wrld.showTrade(t)
+			return "confirmed, waiting on " + t.other(userID), nil
+		}
+		if err := wrld.settle(t); err != nil {
+			wrld.closeTrade(t, "trade failed: "+err.Error())
+			return "", err
+		}
+		wrld.closeTrade(t, "trade complete")
+		return "trade complete", nil
+
+	case "cancel":
+		if t == nil {
+			return "", fmt.Errorf("not trading")
+		}
+		wrld.closeTrade(t, fmt.Sprintf("%s cancelled the trade", userID))
+		return "", nil
+	}
+
+	if len(args) != 1 {
+		return "", fmt.Errorf("usage: trade [<user>|offer <item>|offer gold <n>|confirm|cancel]")
+	}
+	otherID, ok := wrld.findPlayer(args[0])
+	if !ok || otherID == userID {
+		return "", fmt.Errorf("no player %q", args[0])
+	}
+	if t != nil {
+		return "", fmt.Errorf("already trading with %s", t.other(userID))
+	}
+	if wrld.trades[otherID] != nil {
+		return "", fmt.Errorf("%s is busy trading", otherID)
+	}
+	if !wrld.inReach(userID, otherID) {
+		return "", fmt.Errorf("%s is too far away to trade", otherID)
+	}
+
+	tmpUser := wrld.users[userID]
+	if tmpUser.tradeRequest != otherID {
+		other := wrld.users[otherID]
+		other.tradeRequest = userID
+		wrld.users[otherID] = other
+		wrld.notify(otherID, fmt.Sprintf("%s wants to trade, :trade %s to accept", userID, userID))
+		return fmt.Sprintf("asked %s to trade", otherID), nil
+	}
+	tmpUser.tradeRequest = ""
+	wrld.users[userID] = tmpUser
+	t = &trade{
+		parties:   [2]string{otherID, userID},
+		offers:    map[string]*tradeOffer{otherID: {}, userID: {}},
+		confirmed: make(map[string]bool),
+	}
+	wrld.trades[otherID], wrld.trades[userID] = t, t
+	wrld.showTrade(t)
+	return "", nil
+}
+
+// offer puts an item, or gold, from the user onto the table
+func (wrld *world) offer(userID string, t *trade, args []string) error {
+	mine := t.offers[userID]
+	u := wrld.users[userID]
+	if len(args) == 2 && args[0] == "gold" {
+		gold, err := strconv.Atoi(args[1])
+		if err != nil || gold <= 0 {
+			return fmt.Errorf("usage: trade offer gold <n>")
+		}
+		if mine.gold+gold > u.gold {
+			return fmt.Errorf("you only have %d gold", u.gold)
+		}
+		mine.gold += gold
+		return nil
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: trade offer <item>|gold <n>")
+	}
+	_, held := itemCounts(u.inventory)
+	_, offered := itemCounts(mine.items)
+	if held[args[0]] <= offered[args[0]] {
+		return fmt.Errorf("no more %s in inventory", args[0])
+	}
+	mine.items = append(mine.items, args[0])
+	return nil
+}
+
+// settle swaps both offers, or nothing at all when either side no longer
+// has what they offered or has no room for what they get
+func (wrld *world) settle(t *trade) error {
+	a, b := t.parties[0], t.parties[1]
+	if !wrld.inReach(a, b) {
+		return fmt.Errorf("too far apart")
+	}
+	for _, id := range t.parties {
+		u := wrld.users[id]
+		mine, theirs := t.offers[id], t.offers[t.other(id)]
+		if u.gold < mine.gold {
+			return fmt.Errorf("%s is short of gold", id)
+		}
+		_, held := itemCounts(u.inventory)
+		_, offered := itemCounts(mine.items)
+		for item, n := range offered {
+			if held[item] < n {
+				return fmt.Errorf("%s no longer has %s", id, item)
+			}
+		}
+		if len(u.inventory)-len(mine.items)+len(theirs.items) > inventoryCapacity {
+			return fmt.Errorf("%s has no room", id)
+		}
+	}
+
+	users := map[string]user{a: wrld.users[a], b: wrld.users[b]}
+	for _, id := range t.parties {
+		u := users[id]
+		for _, item := range t.offers[id].items {
+			u.takeItem(item)
+		}
+		u.gold -= t.offers[id].gold
+		users[id] = u
+	}
+	for _, id := range t.parties {
+		u := users[id]
+		theirs := t.offers[t.other(id)]
+		u.inventory = append(u.inventory, theirs.items...)
+		u.gold += theirs.gold
+		wrld.users[id] = u
+	}
+	return nil
+}
+
+// closeTrade ends the trade for both parties, telling them why
+func (wrld *world) closeTrade(t *trade, reason string) {
+	for _, id := range t.parties {
+		delete(wrld.trades, id)
+		if u, ok := wrld.users[id]; ok && u.activeModal == "trade" {
+			u.modal = loadModal("")
+			u.activeModal = ""
+			wrld.users[id] = u
+		}
+		wrld.notify(id, reason)
+	}
+}
+
+// showTrade puts the trade window up for both parties
+func (wrld *world) showTrade(t *trade) {
+	for _, id := range t.parties {
+		other := t.other(id)
+		lines := append([]string{"You offer:"}, wrld.offerRows(t, id)...)
+		lines = append(lines, "", other+" offers:")
+		lines = append(lines, wrld.offerRows(t, other)...)
+		lines = append(lines, "", "trade offer <item>|gold <n>", "trade confirm, trade cancel")
+		if u, ok := wrld.users[id]; ok {
+			u.modal = loadModal(textModal("Trade with "+other, lines))
+			u.activeModal = "trade"
+			wrld.users[id] = u
+		}
+	}
+}
+
+func (wrld *world) offerRows(t *trade, userID string) []string {
+	o := t.offers[userID]
+	ids, counts := itemCounts(o.items)
+	rows := make([]string, 0, len(ids)+2)
+	for _, id := range ids {
+		rows = append(rows, fmt.Sprintf("  %s x%d", wrld.itemTypes[id].Name, counts[id]))
+	}
+	if o.gold > 0 {
+		rows = append(rows, fmt.Sprintf("  %d gold", o.gold))
+	}
+	if len(rows) == 0 {
+		rows = append(rows, "  nothing")
+	}
+	if t.confirmed[userID] {
+		rows = append(rows, "  (confirmed)")
+	}
+	return rows
+}
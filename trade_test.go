@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+// openTrade has Alice ask Bob to trade and Bob accept
+func openTrade(t *testing.T, w *world) {
+	if _, err := w.tradeCommand("Alice", []string{"Bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if w.trades["Alice"] != nil {
+		t.Fatal("expected no trade before it is accepted")
+	}
+	if _, err := w.tradeCommand("Bob", []string{"Alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if w.trades["Alice"] == nil || w.trades["Alice"] != w.trades["Bob"] {
+		t.Fatal("expected a trade between Alice and Bob")
+	}
+}
+
+func TestTrade(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("Alice", 80, 20, position{x: 2, y: 3}, false)
+	w.createUser("Bob", 80, 20, position{x: 3, y: 3}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	alice, bob := w.users["Alice"], w.users["Bob"]
+	alice.inventory, bob.inventory = []string{"potion", "bone"}, nil
+	bob.gold = 30
+	w.users["Alice"], w.users["Bob"] = alice, bob
+
+	openTrade(t, w)
+	if _, err := w.tradeCommand("Alice", []string{"offer", "sword"}); err == nil {
+		t.Error("expected items not held to be refused")
+	}
+	if _, err := w.tradeCommand("Bob", []string{"offer", "gold", "50"}); err == nil {
+		t.Error("expected more gold than held to be refused")
+	}
+	w.tradeCommand("Alice", []string{"offer", "potion"})
+	w.tradeCommand("Bob", []string{"offer", "gold", "10"})
+	w.tradeCommand("Alice", []string{"confirm"})
+	w.tradeCommand("Bob", []string{"offer", "gold", "5"})
+	if w.trades["Alice"].confirmed["Alice"] {
+		t.Fatal("expected a changed offer to take the confirmation back")
+	}
+	if modal := modalText(w.users["Alice"].modal); !strings.Contains(modal, "15 gold") {
+		t.Errorf("expected the offer in the trade window:\n%s", modal)
+	}
+
+	w.tradeCommand("Alice", []string{"confirm"})
+	if _, err := w.tradeCommand("Bob", []string{"confirm"}); err != nil {
+		t.Fatal(err)
+	}
+	alice, bob = w.users["Alice"], w.users["Bob"]
+	if strings.Join(alice.inventory, ",") != "bone" || alice.gold != 15 {
+		t.Errorf("expected Alice to have the gold, have %v and %d", alice.inventory, alice.gold)
+	}
+	if strings.Join(bob.inventory, ",") != "potion" || bob.gold != 15 {
+		t.Errorf("expected Bob to have the potion, have %v and %d", bob.inventory, bob.gold)
+	}
+	if w.trades["Alice"] != nil || w.trades["Bob"] != nil {
+		t.Error("expected the trade closed")
+	}
+}
+
+func TestTradeAllOrNothing(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("Alice", 80, 20, position{x: 2, y: 3}, false)
+	w.createUser("Bob", 80, 20, position{x: 3, y: 3}, false)
+	w.Lock()
+	defer w.Unlock()
+
+	alice, bob := w.users["Alice"], w.users["Bob"]
+	alice.inventory, bob.inventory = []string{"potion"}, []string{"bone"}
+	w.users["Alice"], w.users["Bob"] = alice, bob
+
+	openTrade(t, w)
+	w.tradeCommand("Alice", []string{"offer", "potion"})
+	w.tradeCommand("Bob", []string{"offer", "bone"})
+	w.tradeCommand("Alice", []string{"confirm"})
+	// the potion is used up before Bob confirms
+	alice = w.users["Alice"]
+	alice.takeItem("potion")
+	w.users["Alice"] = alice
+	if _, err := w.tradeCommand("Bob", []string{"confirm"}); err == nil {
+		t.Fatal("expected the trade to fail")
+	}
+	if len(w.users["Alice"].inventory) != 0 || strings.Join(w.users["Bob"].inventory, ",") != "bone" {
+		t.Errorf("expected nothing to change hands, Alice %v Bob %v", w.users["Alice"].inventory, w.users["Bob"].inventory)
+	}
+	if w.trades["Bob"] != nil {
+		t.Error("expected the failed trade closed")
+	}
+
+	w.teleport("Bob", position{x: 20, y: 3})
+	if _, err := w.tradeCommand("Alice", []string{"Bob"}); err == nil {
+		t.Error("expected players too far apart not to trade")
+	}
+}
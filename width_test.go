@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var sgrPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func TestRuneWidth(t *testing.T) {
+	cases := map[rune]int{
+		'a':    1,
+		'┃':    1,
+		'☃':    1,
+		'⛄':    2,
+		'日':    2,
+		'😀':    2,
+		0x0301: 0, // combining acute accent
+		0xfe0f: 0, // emoji presentation selector
+		0:      0,
+	}
+	for r, want := range cases {
+		if got := runeWidth(r); got != want {
+			t.Errorf("runeWidth(%U) = %d, want %d", r, got, want)
+		}
+	}
+}
+
+func TestFitCells(t *testing.T) {
+	// cutting through a wide rune must not leave half of it behind
+	cells := fitCells(textCells("ab日"), 3)
+	if got := string(cells); got != "ab " {
+		t.Errorf("unexpected cells %q", got)
+	}
+}
+
+func TestDisplayRowWidth(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+	w.createUser("testingUser", 30, 12, position{x: 2, y: 3}, false)
+	w.createUser("neighbor", 30, 12, position{x: 3, y: 3}, false)
+	w.locations[0].positions["2,3"].userID = "testingUser"
+	w.locations[0].positions["3,3"].userID = "neighbor"
+
+	for _, id := range []string{"testingUser", "neighbor"} {
+		tmpUser := w.users[id]
+		tmpUser.character = '⛄'
+		w.users[id] = tmpUser
+	}
+
+	modals := []string{
+		help(),
+		"\n┌────┐\n│日本│▒\n│é ⛄│▒\n└────┘▒\n",
+		"\n 😀😀😀😀😀😀😀😀😀😀😀😀😀😀😀😀😀😀\n",
+	}
+	for _, color := range []bool{false, true} {
+		for _, modal := range modals {
+			for _, width := range []int{29, 30, 31} {
+				tmpUser := w.users["testingUser"]
+				tmpUser.modal = loadModal(modal)
+				tmpUser.color = color
+				w.users["testingUser"] = tmpUser
+
+				out := sgrPattern.ReplaceAllString(string(w.display("testingUser", width, 12)), "")
+				rows := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+				if len(rows) != 12 {
+					t.Errorf("got %d rows, want 12", len(rows))
+				}
+				for i, row := range rows {
+					if got := stringWidth(row); got != width {
+						t.Errorf("color=%v width=%d row %d is %d columns wide: %q", color, width, i, got, row)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestNegativeViewport(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	w := genWorld("maps/map_1.map", 0, 2)
+
+	rec := httptest.NewRecorder()
+	getWorld(w)(rec, httptest.NewRequest("GET", "/?uid=testingUser&w=-5&h=10", nil))
+	if rec.Code != 200 {
+		t.Errorf("expected an empty screen, got status %d", rec.Code)
+	}
+}